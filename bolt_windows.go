@@ -65,6 +65,7 @@ func flock(db *DB, mode os.FileMode, exclusive bool, timeout time.Duration) erro
 		if t.IsZero() {
 			t = time.Now()
 		} else if timeout > 0 && time.Since(t) > timeout {
+			db.logger.Warningf("bolt: timed out after %s waiting for file lock on %s", timeout, db.path)
 			return ErrTimeout
 		}
 
@@ -81,6 +82,7 @@ func flock(db *DB, mode os.FileMode, exclusive bool, timeout time.Duration) erro
 		}
 
 		// Wait for a bit and try again.
+		db.logger.Debugf("bolt: file lock on %s held by another process, retrying", db.path)
 		time.Sleep(50 * time.Millisecond)
 	}
 }
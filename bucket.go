@@ -2,7 +2,9 @@ package bolt
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
+	"math"
 	"unsafe"
 )
 
@@ -125,6 +127,19 @@ func (b *Bucket) Bucket(name []byte) *Bucket {
 	return child
 }
 
+// lookupBucket retrieves a nested bucket by name. Unlike Bucket, it returns
+// ErrBucketNotFound if the key does not exist and ErrIncompatibleValue if the
+// key exists but is not a bucket.
+func (b *Bucket) lookupBucket(name []byte) (*Bucket, error) {
+	if child := b.Bucket(name); child != nil {
+		return child, nil
+	}
+	if k, _, _ := b.Cursor().seek(name); bytes.Equal(name, k) {
+		return nil, ErrIncompatibleValue
+	}
+	return nil, ErrBucketNotFound
+}
+
 // Helper method that re-interprets a sub-bucket value
 // from a parent into a Bucket
 func (b *Bucket) openBucket(value []byte) *Bucket {
@@ -190,6 +205,7 @@ func (b *Bucket) CreateBucket(key []byte) (*Bucket, error) {
 	// Insert into node.
 	key = cloneBytes(key)
 	c.node().put(key, key, value, 0, bucketLeafFlag)
+	b.tx.writeBytes += len(key) + len(value)
 
 	// Since subbuckets are not allowed on inline buckets, we need to
 	// dereference the inline page, if it exists. This will cause the bucket
@@ -256,6 +272,7 @@ func (b *Bucket) DeleteBucket(key []byte) error {
 
 	// Delete the node if we have a matching key.
 	c.node().del(key)
+	b.tx.writeBytes += len(key)
 
 	return nil
 }
@@ -307,6 +324,7 @@ func (b *Bucket) Put(key []byte, value []byte) error {
 	// Insert into node.
 	key = cloneBytes(key)
 	c.node().put(key, key, value, 0, 0)
+	b.tx.writeBytes += len(key) + len(value)
 
 	return nil
 }
@@ -332,7 +350,288 @@ func (b *Bucket) Delete(key []byte) error {
 
 	// Delete the node if we have a matching key.
 	c.node().del(key)
+	b.tx.writeBytes += len(key)
+
+	return nil
+}
+
+// DeleteRange removes all keys in the range [start, end) from the bucket.
+// A nil start or end leaves that side of the range unbounded. Nested buckets
+// within the range are deleted along with their contents.
+//
+// Pages that lie entirely within the range are released to the freelist
+// without being read into memory so only the pages on the boundaries of the
+// range are rewritten when the transaction commits.
+// Returns an error if the bucket was created from a read-only transaction.
+func (b *Bucket) DeleteRange(start, end []byte) error {
+	if b.tx.db == nil {
+		return ErrTxClosed
+	} else if !b.Writable() {
+		return ErrTxNotWritable
+	} else if start != nil && end != nil && bytes.Compare(start, end) >= 0 {
+		return nil
+	}
+
+	n := b.node(b.root, nil)
+	b.deleteRange(n, start, end)
+	b.collapse(n)
+	b.tx.writeBytes += len(start) + len(end)
+	return nil
+}
+
+// Clear removes all keys and nested buckets from the bucket.
+// Returns an error if the bucket was created from a read-only transaction.
+func (b *Bucket) Clear() error {
+	return b.DeleteRange(nil, nil)
+}
+
+// deleteRange removes the keys in [start, end) from the subtree rooted at n.
+// Children of a branch that are entirely within the range are freed while
+// children that overlap the range are materialized and processed recursively.
+// Children emptied by the recursion are removed and children left with a
+// single child of their own are collapsed so that every branch below n still
+// has at least two children, which rebalance relies on.
+func (b *Bucket) deleteRange(n *node, start, end []byte) {
+	var j int
+	for i, inode := range n.inodes {
+		if n.isLeaf {
+			if (start == nil || bytes.Compare(inode.key, start) >= 0) && (end == nil || bytes.Compare(inode.key, end) < 0) {
+				if (inode.flags & bucketLeafFlag) != 0 {
+					b.freeBucketValue(inode.key, inode.value)
+				}
+				continue
+			}
+		} else {
+			// A child holds the keys from its own key up to the key of the
+			// next child. Keys before the first child's key belong to it.
+			first, last := i == 0, i == len(n.inodes)-1
+			below := start != nil && !last && bytes.Compare(n.inodes[i+1].key, start) <= 0
+			above := end != nil && !first && bytes.Compare(inode.key, end) >= 0
+			covered := (start == nil || (!first && bytes.Compare(inode.key, start) >= 0)) &&
+				(end == nil || (!last && bytes.Compare(n.inodes[i+1].key, end) <= 0))
+
+			if covered {
+				b.freeSubtree(inode.pgid)
+				continue
+			} else if !below && !above {
+				child := n.childAt(i)
+				b.deleteRange(child, start, end)
+				b.collapse(child)
+				if len(child.inodes) == 0 {
+					n.removeChild(child)
+					delete(b.nodes, child.pgid)
+					child.free()
+					continue
+				}
+			}
+		}
+		n.inodes[j] = inode
+		j++
+	}
+
+	if j < len(n.inodes) {
+		n.inodes = n.inodes[:j]
+		n.unbalanced = true
+	}
+}
+
+// collapse replaces a branch that has a single child with the contents of
+// that child. A branch without any children becomes an empty leaf.
+func (b *Bucket) collapse(n *node) {
+	if n.isLeaf {
+		return
+	} else if len(n.inodes) == 0 {
+		n.isLeaf = true
+		return
+	} else if len(n.inodes) > 1 {
+		return
+	}
+
+	// Move the child up.
+	child := b.node(n.inodes[0].pgid, n)
+	n.isLeaf = child.isLeaf
+	n.inodes = child.inodes[:]
+	n.children = child.children
+
+	// Reparent all child nodes being moved.
+	if !n.isLeaf {
+		for _, inode := range n.inodes {
+			if child, ok := b.nodes[inode.pgid]; ok {
+				child.parent = n
+			}
+		}
+	}
+
+	// Remove old child.
+	child.parent = nil
+	delete(b.nodes, child.pgid)
+	child.free()
+}
+
+// freeSubtree releases every page in the subtree rooted at pgid, along with
+// any nested buckets stored in its leaves, and discards materialized nodes.
+func (b *Bucket) freeSubtree(pgid pgid) {
+	p, n := b.pageNode(pgid)
+	if n != nil {
+		for _, inode := range n.inodes {
+			if !n.isLeaf {
+				b.freeSubtree(inode.pgid)
+			} else if (inode.flags & bucketLeafFlag) != 0 {
+				b.freeBucketValue(inode.key, inode.value)
+			}
+		}
+		if n.parent != nil {
+			n.parent.removeChild(n)
+		}
+		delete(b.nodes, pgid)
+		n.free()
+		return
+	}
+
+	if (p.flags & leafPageFlag) != 0 {
+		for i := 0; i < int(p.count); i++ {
+			if elem := p.leafPageElement(uint16(i)); (elem.flags & bucketLeafFlag) != 0 {
+				b.freeBucketValue(p.leafKey(uint16(i), &keyBuffer{}), elem.value())
+			}
+		}
+	} else {
+		for i := 0; i < int(p.count); i++ {
+			b.freeSubtree(p.branchPageElement(uint16(i)).pgid)
+		}
+	}
+	b.tx.db.freelist.free(b.tx.meta.txid, p)
+}
+
+// freeBucketValue releases the pages of the nested bucket stored under key
+// and all of its own nested buckets.
+func (b *Bucket) freeBucketValue(key, value []byte) {
+	child := b.buckets[string(key)]
+	if child == nil {
+		child = b.openBucket(value)
+	}
+	delete(b.buckets, string(key))
+	child.freeAll()
+}
 
+// freeAll releases the pages of the bucket and all of its nested buckets.
+func (b *Bucket) freeAll() {
+	_ = b.ForEach(func(k, v []byte) error {
+		if v == nil {
+			b.Bucket(k).freeAll()
+		}
+		return nil
+	})
+	b.nodes = nil
+	b.rootNode = nil
+	b.free()
+}
+
+// CompareAndSwap sets the value for a key only if its current value equals old.
+// A nil old value requires that the key does not exist and a nil value
+// deletes the key. Returns ErrValueMismatch if the current value differs.
+// Supplied value must remain valid for the life of the transaction.
+func (b *Bucket) CompareAndSwap(key, old, value []byte) error {
+	c, v, exists, err := b.seekValue(key)
+	if err != nil {
+		return err
+	}
+
+	// Compare the current value.
+	if old == nil {
+		if exists {
+			return ErrValueMismatch
+		}
+	} else if !exists || !bytes.Equal(v, old) {
+		return ErrValueMismatch
+	}
+
+	// Delete the key if no new value is provided.
+	if value == nil {
+		if exists {
+			c.node().del(key)
+			b.tx.writeBytes += len(key)
+		}
+		return nil
+	}
+	return b.putAt(c, key, value)
+}
+
+// PutIfAbsent sets the value for a key only if the key does not exist.
+// Returns ErrKeyExists if it does.
+// Supplied value must remain valid for the life of the transaction.
+func (b *Bucket) PutIfAbsent(key, value []byte) error {
+	c, _, exists, err := b.seekValue(key)
+	if err != nil {
+		return err
+	} else if exists {
+		return ErrKeyExists
+	}
+	return b.putAt(c, key, value)
+}
+
+// Increment adds delta to the counter stored at key and returns the new value.
+// Counters are stored as 8-byte big endian signed integers. A missing key is
+// treated as zero. Returns ErrInvalidCounter if the existing value is not 8
+// bytes long and ErrCounterOverflow if the result overflows an int64.
+func (b *Bucket) Increment(key []byte, delta int64) (int64, error) {
+	c, v, exists, err := b.seekValue(key)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int64
+	if exists {
+		if len(v) != 8 {
+			return 0, ErrInvalidCounter
+		}
+		n = int64(binary.BigEndian.Uint64(v))
+	}
+
+	// Check for overflow before adding.
+	if (delta > 0 && n > math.MaxInt64-delta) || (delta < 0 && n < math.MinInt64-delta) {
+		return 0, ErrCounterOverflow
+	}
+	n += delta
+
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, uint64(n))
+	if err := b.putAt(c, key, value); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// seekValue validates a write to key and moves a cursor to it.
+// Returns the cursor, the current value and whether the key exists.
+func (b *Bucket) seekValue(key []byte) (c *Cursor, value []byte, exists bool, err error) {
+	if b.tx.db == nil {
+		return nil, nil, false, ErrTxClosed
+	} else if !b.Writable() {
+		return nil, nil, false, ErrTxNotWritable
+	} else if len(key) == 0 {
+		return nil, nil, false, ErrKeyRequired
+	} else if len(key) > MaxKeySize {
+		return nil, nil, false, ErrKeyTooLarge
+	}
+
+	c = b.Cursor()
+	k, v, flags := c.seek(key)
+	if !bytes.Equal(key, k) {
+		return c, nil, false, nil
+	} else if (flags & bucketLeafFlag) != 0 {
+		return nil, nil, false, ErrIncompatibleValue
+	}
+	return c, v, true, nil
+}
+
+// putAt inserts a key/value pair at the cursor position found by seekValue.
+func (b *Bucket) putAt(c *Cursor, key, value []byte) error {
+	if int64(len(value)) > MaxValueSize {
+		return ErrValueTooLarge
+	}
+	key = cloneBytes(key)
+	c.node().put(key, key, value, 0, 0)
+	b.tx.writeBytes += len(key) + len(value)
 	return nil
 }
 
@@ -591,20 +890,16 @@ func (b *Bucket) inlineable() bool {
 		return false
 	}
 
-	// Bucket is not inlineable if it contains subbuckets or if it goes beyond
-	// our threshold for inline bucket size.
-	var size = pageHeaderSize
+	// Bucket is not inlineable if it contains subbuckets.
 	for _, inode := range n.inodes {
-		size += leafPageElementSize + len(inode.key) + len(inode.value)
-
 		if inode.flags&bucketLeafFlag != 0 {
 			return false
-		} else if size > b.maxInlineBucketSize() {
-			return false
 		}
 	}
 
-	return true
+	// Or if it goes beyond our threshold for inline bucket size. The size is
+	// computed as the node is written, which accounts for a shared key prefix.
+	return n.sizeLessThan(b.maxInlineBucketSize() + 1)
 }
 
 // Returns the maximum total size of a bucket to make it a candidate for inlining.
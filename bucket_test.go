@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"math/rand"
 	"os"
 	"strconv"
@@ -824,6 +825,396 @@ func TestBucket_Sequence(t *testing.T) {
 	}
 }
 
+// Ensure that a value is only swapped when it matches the expected value.
+func TestBucket_CompareAndSwap(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := b.CreateBucket([]byte("sub")); err != nil {
+			t.Fatal(err)
+		}
+
+		// Create the key only if it doesn't exist.
+		if err := b.CompareAndSwap([]byte("foo"), nil, []byte("bar")); err != nil {
+			t.Fatal(err)
+		} else if err := b.CompareAndSwap([]byte("foo"), nil, []byte("baz")); err != bolt.ErrValueMismatch {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// Swap the value only if it matches.
+		if err := b.CompareAndSwap([]byte("foo"), []byte("xxx"), []byte("baz")); err != bolt.ErrValueMismatch {
+			t.Fatalf("unexpected error: %v", err)
+		} else if err := b.CompareAndSwap([]byte("foo"), []byte("bar"), []byte("baz")); err != nil {
+			t.Fatal(err)
+		} else if v := b.Get([]byte("foo")); !bytes.Equal(v, []byte("baz")) {
+			t.Fatalf("unexpected value: %q", v)
+		}
+
+		// Delete the key only if it matches.
+		if err := b.CompareAndSwap([]byte("foo"), []byte("baz"), nil); err != nil {
+			t.Fatal(err)
+		} else if v := b.Get([]byte("foo")); v != nil {
+			t.Fatalf("unexpected value: %q", v)
+		} else if err := b.CompareAndSwap([]byte("foo"), []byte("baz"), nil); err != bolt.ErrValueMismatch {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if err := b.CompareAndSwap([]byte("sub"), nil, []byte("x")); err != bolt.ErrIncompatibleValue {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.View(func(tx *bolt.Tx) error {
+		if err := tx.Bucket([]byte("widgets")).CompareAndSwap([]byte("foo"), nil, nil); err != bolt.ErrTxNotWritable {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure that a value is only put if the key does not exist.
+func TestBucket_PutIfAbsent(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := b.PutIfAbsent([]byte("foo"), []byte("bar")); err != nil {
+			t.Fatal(err)
+		} else if err := b.PutIfAbsent([]byte("foo"), []byte("baz")); err != bolt.ErrKeyExists {
+			t.Fatalf("unexpected error: %v", err)
+		} else if v := b.Get([]byte("foo")); !bytes.Equal(v, []byte("bar")) {
+			t.Fatalf("unexpected value: %q", v)
+		} else if err := b.PutIfAbsent(nil, []byte("bar")); err != bolt.ErrKeyRequired {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure that counters can be incremented and persisted.
+func TestBucket_Increment(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n, err := b.Increment([]byte("count"), 5); err != nil {
+			t.Fatal(err)
+		} else if n != 5 {
+			t.Fatalf("unexpected value: %d", n)
+		}
+		if n, err := b.Increment([]byte("count"), -7); err != nil {
+			t.Fatal(err)
+		} else if n != -2 {
+			t.Fatalf("unexpected value: %d", n)
+		}
+
+		if err := b.Put([]byte("bad"), []byte("x")); err != nil {
+			t.Fatal(err)
+		} else if _, err := b.Increment([]byte("bad"), 1); err != bolt.ErrInvalidCounter {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if _, err := b.Increment([]byte("max"), math.MaxInt64); err != nil {
+			t.Fatal(err)
+		} else if _, err := b.Increment([]byte("max"), 1); err != bolt.ErrCounterOverflow {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket([]byte("widgets")).Get([]byte("count"))
+		if n := int64(binary.BigEndian.Uint64(v)); n != -2 {
+			t.Fatalf("unexpected value: %d", n)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure that a range of keys spanning many pages can be deleted.
+func TestBucket_DeleteRange(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+
+	key := func(i int) []byte { return []byte(fmt.Sprintf("%08d", i)) }
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 10000; i++ {
+			if err := b.Put(key(i), bytes.Repeat([]byte{'*'}, 100)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// Add a large nested bucket within the range.
+		child, err := b.CreateBucket([]byte("00005000.sub"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 1000; i++ {
+			if err := child.Put(key(i), bytes.Repeat([]byte{'-'}, 100)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("widgets"))
+
+		// Materialize a node inside the range before deleting.
+		if err := b.Put(key(7000), []byte("updated")); err != nil {
+			t.Fatal(err)
+		}
+		return b.DeleteRange(key(1000), key(9000))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	db.MustCheck()
+
+	if err := db.View(func(tx *bolt.Tx) error {
+		var n int
+		c := tx.Bucket([]byte("widgets")).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if v == nil {
+				t.Fatalf("unexpected bucket: %s", k)
+			} else if bytes.Compare(k, key(1000)) >= 0 && bytes.Compare(k, key(9000)) < 0 {
+				t.Fatalf("unexpected key: %s", k)
+			}
+			n++
+		}
+		if n != 2000 {
+			t.Fatalf("unexpected key count: %d", n)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Delete ranges that are unbounded on one side.
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("widgets"))
+		if err := b.DeleteRange(nil, key(500)); err != nil {
+			t.Fatal(err)
+		} else if err := b.DeleteRange(key(9500), nil); err != nil {
+			t.Fatal(err)
+		}
+
+		if k, _ := b.Cursor().First(); !bytes.Equal(k, key(500)) {
+			t.Fatalf("unexpected first key: %s", k)
+		} else if k, _ := b.Cursor().Last(); !bytes.Equal(k, key(9499)) {
+			t.Fatalf("unexpected last key: %s", k)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure that deleting every key of a multi-level bucket with a bounded range
+// leaves a valid tree.
+func TestBucket_DeleteRange_All(t *testing.T) {
+	for _, tt := range []struct {
+		start, end []byte
+	}{
+		{[]byte("a"), nil},
+		{[]byte("a"), []byte("z")},
+		{nil, []byte("z")},
+	} {
+		db := MustOpenDB()
+		if err := db.Update(func(tx *bolt.Tx) error {
+			b, err := tx.CreateBucket([]byte("widgets"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 20000; i++ {
+				if err := b.Put([]byte(fmt.Sprintf("k%08d", i)), bytes.Repeat([]byte{'*'}, 100)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.View(func(tx *bolt.Tx) error {
+			if depth := tx.Bucket([]byte("widgets")).Stats().Depth; depth < 3 {
+				t.Fatalf("unexpected depth: %d", depth)
+			}
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := db.Update(func(tx *bolt.Tx) error {
+			return tx.Bucket([]byte("widgets")).DeleteRange(tt.start, tt.end)
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := db.View(func(tx *bolt.Tx) error {
+			for err := range tx.Check() {
+				t.Fatalf("%q-%q: %s", tt.start, tt.end, err)
+			}
+			return tx.Bucket([]byte("widgets")).ForEach(func(k, v []byte) error {
+				t.Fatalf("%q-%q: unexpected key: %s", tt.start, tt.end, k)
+				return nil
+			})
+		}); err != nil {
+			t.Fatal(err)
+		}
+		db.MustClose()
+	}
+}
+
+// Ensure that random ranges are deleted correctly.
+func TestBucket_DeleteRange_Random(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	for iter := 0; iter < 20; iter++ {
+		db := MustOpenDB()
+
+		keys := make(map[string]bool)
+		if err := db.Update(func(tx *bolt.Tx) error {
+			b, err := tx.CreateBucket([]byte("widgets"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 2000; i++ {
+				k := fmt.Sprintf("%06d", r.Intn(100000))
+				keys[k] = true
+				if err := b.Put([]byte(k), make([]byte, r.Intn(200))); err != nil {
+					t.Fatal(err)
+				}
+			}
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := db.Update(func(tx *bolt.Tx) error {
+			b := tx.Bucket([]byte("widgets"))
+			for i := 0; i < 3; i++ {
+				start, end := fmt.Sprintf("%06d", r.Intn(100000)), fmt.Sprintf("%06d", r.Intn(100000))
+				if err := b.DeleteRange([]byte(start), []byte(end)); err != nil {
+					t.Fatal(err)
+				}
+				for k := range keys {
+					if k >= start && k < end {
+						delete(keys, k)
+					}
+				}
+			}
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := db.View(func(tx *bolt.Tx) error {
+			var n int
+			if err := tx.Bucket([]byte("widgets")).ForEach(func(k, v []byte) error {
+				if !keys[string(k)] {
+					t.Fatalf("unexpected key: %s", k)
+				}
+				n++
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if n != len(keys) {
+				t.Fatalf("unexpected key count: %d != %d", n, len(keys))
+			}
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		db.MustClose()
+	}
+}
+
+// Ensure that a bucket can be cleared of all keys and nested buckets.
+func TestBucket_Clear(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 1000; i++ {
+			if err := b.Put([]byte(strconv.Itoa(i)), bytes.Repeat([]byte{'*'}, 100)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		child, err := b.CreateBucket([]byte("sub"))
+		if err != nil {
+			t.Fatal(err)
+		} else if _, err := child.CreateBucket([]byte("subsub")); err != nil {
+			t.Fatal(err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("widgets"))
+		if err := b.Clear(); err != nil {
+			t.Fatal(err)
+		} else if k, _ := b.Cursor().First(); k != nil {
+			t.Fatalf("unexpected key: %s", k)
+		} else if err := b.Put([]byte("foo"), []byte("bar")); err != nil {
+			t.Fatal(err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("widgets"))
+		if b.Bucket([]byte("sub")) != nil {
+			t.Fatal("expected nested bucket to be deleted")
+		} else if stats := b.Stats(); stats.KeyN != 1 {
+			t.Fatalf("unexpected key count: %d", stats.KeyN)
+		}
+
+		if err := b.Clear(); err != bolt.ErrTxNotWritable {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
 // Ensure that a bucket can return an autoincrementing sequence.
 func TestBucket_NextSequence(t *testing.T) {
 	db := MustOpenDB()
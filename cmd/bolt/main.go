@@ -1,19 +1,29 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/base64"
 	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"hash/fnv"
 	"io"
 	"io/ioutil"
 	"math/rand"
+	"net/http"
 	"os"
 	"runtime"
 	"runtime/pprof"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
 	"time"
 	"unicode"
 	"unicode/utf8"
@@ -54,6 +64,18 @@ var (
 
 	// ErrPageFreed is returned when reading a page that has already been freed.
 	ErrPageFreed = errors.New("page freed")
+
+	// ErrBucketRequired is returned when a required bucket is not specified.
+	ErrBucketRequired = errors.New("bucket required")
+
+	// ErrKeyRequired is returned when a required key is not specified.
+	ErrKeyRequired = errors.New("key required")
+
+	// ErrValueRequired is returned when a required value is not specified.
+	ErrValueRequired = errors.New("value required")
+
+	// ErrKeyNotFound is returned when a key does not exist in a bucket.
+	ErrKeyNotFound = errors.New("key not found")
 )
 
 // PageHeaderSize represents the size of the bolt.page header.
@@ -100,20 +122,50 @@ func (m *Main) Run(args ...string) error {
 		return ErrUsage
 	case "bench":
 		return newBenchCommand(m).Run(args[1:]...)
+	case "buckets":
+		return newBucketsCommand(m).Run(args[1:]...)
 	case "check":
 		return newCheckCommand(m).Run(args[1:]...)
 	case "compact":
 		return newCompactCommand(m).Run(args[1:]...)
+	case "convert-endian":
+		return newConvertEndianCommand(m).Run(args[1:]...)
+	case "delete":
+		return newDeleteCommand(m).Run(args[1:]...)
+	case "diff":
+		return newDiffCommand(m).Run(args[1:]...)
+	case "downgrade":
+		return newUpgradeCommand(m, true).Run(args[1:]...)
+	case "du":
+		return newDuCommand(m).Run(args[1:]...)
 	case "dump":
 		return newDumpCommand(m).Run(args[1:]...)
+	case "export":
+		return newExportCommand(m).Run(args[1:]...)
+	case "frag":
+		return newFragCommand(m).Run(args[1:]...)
+	case "get":
+		return newGetCommand(m).Run(args[1:]...)
+	case "import":
+		return newImportCommand(m).Run(args[1:]...)
 	case "info":
 		return newInfoCommand(m).Run(args[1:]...)
+	case "keys":
+		return newKeysCommand(m).Run(args[1:]...)
 	case "page":
 		return newPageCommand(m).Run(args[1:]...)
 	case "pages":
 		return newPagesCommand(m).Run(args[1:]...)
+	case "put":
+		return newPutCommand(m).Run(args[1:]...)
+	case "serve":
+		return newServeCommand(m).Run(args[1:]...)
+	case "shell":
+		return newShellCommand(m).Run(args[1:]...)
 	case "stats":
 		return newStatsCommand(m).Run(args[1:]...)
+	case "upgrade":
+		return newUpgradeCommand(m, false).Run(args[1:]...)
 	default:
 		return ErrUnknownCommand
 	}
@@ -131,12 +183,28 @@ Usage:
 The commands are:
 
     bench       run synthetic benchmark against bolt
+    buckets     print the names of buckets
     check       verifies integrity of bolt database
     compact     copies a bolt database, compacting it in the process
+    convert-endian
+                copies a bolt database, swapping its byte order
+    delete      delete a key
+    diff        compare the contents of two bolt databases
+    downgrade   rewrites a bolt database in an older format version
+    du          print the space used by each bucket
+    export      writes all buckets and keys as JSON Lines
+    frag        print fragmentation of the free pages
+    get         print the value of a key
+    import      loads buckets and keys written by export
     info        print basic info
     help        print this screen
+    keys        print the keys in a bucket
     pages       print list of pages with their types
+    put         set the value of a key
+    serve       serve a read-only HTTP API and browser
+    shell       browse and edit a bolt database interactively
     stats       iterate over all pages and generate usage stats
+    upgrade     rewrites a bolt database in a newer format version
 
 Use "bolt [command] -h" for more information about a command.
 `, "\n")
@@ -273,6 +341,10 @@ func (cmd *InfoCommand) Run(args ...string) error {
 	// Print basic database info.
 	info := db.Info()
 	fmt.Fprintf(cmd.Stdout, "Page Size: %d\n", info.PageSize)
+	fmt.Fprintf(cmd.Stdout, "Version: %d\n", info.Version)
+	if len(info.Features) > 0 {
+		fmt.Fprintf(cmd.Stdout, "Features: %s\n", strings.Join(info.Features, ", "))
+	}
 
 	return nil
 }
@@ -522,6 +594,9 @@ func (cmd *PageCommand) PrintLeaf(w io.Writer, buf []byte) error {
 
 	// Print number of items.
 	fmt.Fprintf(w, "Item Count: %d\n", p.count)
+	if prefix := p.keyPrefix(); prefix != nil {
+		fmt.Fprintf(w, "Key Prefix: %d bytes\n", len(prefix))
+	}
 	fmt.Fprintf(w, "\n")
 
 	// Print each key/value.
@@ -530,10 +605,10 @@ func (cmd *PageCommand) PrintLeaf(w io.Writer, buf []byte) error {
 
 		// Format key as string.
 		var k string
-		if isPrintable(string(e.key())) {
-			k = fmt.Sprintf("%q", string(e.key()))
+		if key := p.leafKey(i); isPrintable(string(key)) {
+			k = fmt.Sprintf("%q", string(key))
 		} else {
-			k = fmt.Sprintf("%x", string(e.key()))
+			k = fmt.Sprintf("%x", string(key))
 		}
 
 		// Format value as string.
@@ -913,7 +988,7 @@ func (cmd *BenchCommand) Run(args ...string) error {
 	}
 
 	// Create database.
-	db, err := bolt.Open(options.Path, 0666, nil)
+	db, err := bolt.Open(options.Path, 0666, &bolt.Options{PageSize: options.PageSize})
 	if err != nil {
 		return err
 	}
@@ -926,15 +1001,37 @@ func (cmd *BenchCommand) Run(args ...string) error {
 		return fmt.Errorf("write: %v", err)
 	}
 
-	// Read from the database.
-	if err := cmd.runReads(db, options, &results); err != nil {
+	// Read from the database, or run a mixed workload against it.
+	if options.Workload == "mixed" {
+		if err := cmd.runMixed(db, options, &results); err != nil {
+			return fmt.Errorf("bench: mixed: %s", err)
+		}
+	} else if err := cmd.runReads(db, options, &results); err != nil {
 		return fmt.Errorf("bench: read: %s", err)
 	}
 
 	// Print results.
-	fmt.Fprintf(os.Stderr, "# Write\t%v\t(%v/op)\t(%v op/sec)\n", results.WriteDuration, results.WriteOpDuration(), results.WriteOpsPerSecond())
-	fmt.Fprintf(os.Stderr, "# Read\t%v\t(%v/op)\t(%v op/sec)\n", results.ReadDuration, results.ReadOpDuration(), results.ReadOpsPerSecond())
-	fmt.Fprintln(os.Stderr, "")
+	if options.Format == "json" {
+		enc := json.NewEncoder(cmd.Stdout)
+		enc.SetIndent("", "\t")
+		return enc.Encode(newBenchReport(options, &results))
+	}
+	fmt.Fprintf(cmd.Stderr, "# Write\t%v\t(%v/op)\t(%v op/sec)\n", results.WriteDuration, results.WriteOpDuration(), results.WriteOpsPerSecond())
+	if options.Workload == "mixed" {
+		fmt.Fprintf(cmd.Stderr, "# Mixed\t%v\t(%v/op)\t(%v op/sec)\n", results.MixedDuration, results.MixedOpDuration(), results.MixedOpsPerSecond())
+		for _, l := range []struct {
+			name string
+			d    []time.Duration
+		}{{"Read", results.ReadLatencies}, {"Write", results.WriteLatencies}} {
+			if len(l.d) > 0 {
+				fmt.Fprintf(cmd.Stderr, "# %s latency\tp50=%v\tp90=%v\tp99=%v\tp99.9=%v\tmax=%v\n", l.name,
+					latencyPercentile(l.d, 50), latencyPercentile(l.d, 90), latencyPercentile(l.d, 99), latencyPercentile(l.d, 99.9), l.d[len(l.d)-1])
+			}
+		}
+	} else {
+		fmt.Fprintf(cmd.Stderr, "# Read\t%v\t(%v/op)\t(%v op/sec)\n", results.ReadDuration, results.ReadOpDuration(), results.ReadOpsPerSecond())
+	}
+	fmt.Fprintln(cmd.Stderr, "")
 	return nil
 }
 
@@ -956,13 +1053,45 @@ func (cmd *BenchCommand) ParseFlags(args []string) (*BenchOptions, error) {
 	fs.StringVar(&options.BlockProfile, "blockprofile", "", "")
 	fs.Float64Var(&options.FillPercent, "fill-percent", bolt.DefaultFillPercent, "")
 	fs.BoolVar(&options.NoSync, "no-sync", false, "")
+	fs.IntVar(&options.PageSize, "page-size", 0, "")
 	fs.BoolVar(&options.Work, "work", false, "")
 	fs.StringVar(&options.Path, "path", "", "")
+	fs.StringVar(&options.Workload, "workload", "rw", "")
+	fs.IntVar(&options.Ops, "ops", 0, "")
+	fs.IntVar(&options.Concurrency, "concurrency", 1, "")
+	fs.Float64Var(&options.ReadRatio, "read-ratio", 0.9, "")
+	fs.StringVar(&options.KeyDist, "key-dist", "uniform", "")
+	fs.StringVar(&options.ValueDist, "value-dist", "fixed", "")
+	fs.BoolVar(&options.Batch, "batch", false, "")
+	fs.StringVar(&options.Format, "format", "text", "")
 	fs.SetOutput(cmd.Stderr)
 	if err := fs.Parse(args); err != nil {
 		return nil, err
 	}
 
+	// Validate the workload options.
+	switch {
+	case options.Workload != "rw" && options.Workload != "mixed":
+		return nil, fmt.Errorf("invalid workload: %s", options.Workload)
+	case options.Workload == "mixed" && options.WriteMode != "seq":
+		return nil, fmt.Errorf("the mixed workload requires the seq write mode")
+	case options.Workload == "mixed" && options.Iterations <= 0:
+		return nil, fmt.Errorf("the mixed workload requires a positive count")
+	case options.Concurrency <= 0:
+		return nil, fmt.Errorf("invalid concurrency: %d", options.Concurrency)
+	case options.ReadRatio < 0 || options.ReadRatio > 1:
+		return nil, fmt.Errorf("invalid read ratio: %v", options.ReadRatio)
+	case options.KeyDist != "uniform" && options.KeyDist != "zipf" && options.KeyDist != "latest":
+		return nil, fmt.Errorf("invalid key distribution: %s", options.KeyDist)
+	case options.ValueDist != "fixed" && options.ValueDist != "uniform" && options.ValueDist != "exp":
+		return nil, fmt.Errorf("invalid value distribution: %s", options.ValueDist)
+	case options.Format != "text" && options.Format != "json":
+		return nil, fmt.Errorf("invalid format: %s", options.Format)
+	}
+	if options.Ops == 0 {
+		options.Ops = options.Iterations
+	}
+
 	// Set batch size to iteration size if not set.
 	// Require that batch size can be evenly divided by the iteration count.
 	if options.BatchSize == 0 {
@@ -1041,6 +1170,7 @@ func (cmd *BenchCommand) runWritesRandomNested(db *bolt.DB, options *BenchOption
 
 func (cmd *BenchCommand) runWritesWithSource(db *bolt.DB, options *BenchOptions, results *BenchResults, keySource func() uint32) error {
 	results.WriteOps = options.Iterations
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
 
 	for i := 0; i < options.Iterations; i += options.BatchSize {
 		if err := db.Update(func(tx *bolt.Tx) error {
@@ -1049,7 +1179,7 @@ func (cmd *BenchCommand) runWritesWithSource(db *bolt.DB, options *BenchOptions,
 
 			for j := 0; j < options.BatchSize; j++ {
 				key := make([]byte, options.KeySize)
-				value := make([]byte, options.ValueSize)
+				value := options.newValue(r)
 
 				// Write key as uint32.
 				binary.BigEndian.PutUint32(key, keySource())
@@ -1070,6 +1200,7 @@ func (cmd *BenchCommand) runWritesWithSource(db *bolt.DB, options *BenchOptions,
 
 func (cmd *BenchCommand) runWritesNestedWithSource(db *bolt.DB, options *BenchOptions, results *BenchResults, keySource func() uint32) error {
 	results.WriteOps = options.Iterations
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
 
 	for i := 0; i < options.Iterations; i += options.BatchSize {
 		if err := db.Update(func(tx *bolt.Tx) error {
@@ -1092,7 +1223,7 @@ func (cmd *BenchCommand) runWritesNestedWithSource(db *bolt.DB, options *BenchOp
 
 			for j := 0; j < options.BatchSize; j++ {
 				var key = make([]byte, options.KeySize)
-				var value = make([]byte, options.ValueSize)
+				var value = options.newValue(r)
 
 				// Generate key as uint32.
 				binary.BigEndian.PutUint32(key, keySource())
@@ -1211,6 +1342,153 @@ func (cmd *BenchCommand) runReadsSequentialNested(db *bolt.DB, options *BenchOpt
 	})
 }
 
+// Runs a mixed workload of reads and writes against the keys written by the
+// seq write mode. The operations are split between concurrent workers and
+// each operation is a read with a probability of ReadRatio.
+func (cmd *BenchCommand) runMixed(db *bolt.DB, options *BenchOptions, results *BenchResults) error {
+	// Start profiling for the mixed workload, which replaces the reads.
+	if options.ProfileMode == "r" {
+		cmd.startProfiling(options)
+	}
+
+	type workerResult struct {
+		reads, writes []time.Duration
+		err           error
+	}
+	workers := make([]workerResult, options.Concurrency)
+	latest := uint32(options.Iterations)
+
+	t := time.Now()
+	var wg sync.WaitGroup
+	for i := range workers {
+		ops := options.Ops / options.Concurrency
+		if i < options.Ops%options.Concurrency {
+			ops++
+		}
+
+		wg.Add(1)
+		go func(w *workerResult, seed int64, ops int) {
+			defer wg.Done()
+			w.reads, w.writes, w.err = cmd.runMixedWorker(db, options, seed, ops, &latest)
+		}(&workers[i], t.UnixNano()+int64(i), ops)
+	}
+	wg.Wait()
+	results.MixedDuration = time.Since(t)
+
+	// Stop profiling for the mixed workload.
+	if options.ProfileMode == "rw" || options.ProfileMode == "r" {
+		cmd.stopProfiling()
+	}
+
+	// Merge the latencies of every worker.
+	for _, w := range workers {
+		if w.err != nil {
+			return w.err
+		}
+		results.ReadLatencies = append(results.ReadLatencies, w.reads...)
+		results.WriteLatencies = append(results.WriteLatencies, w.writes...)
+	}
+	sort.Slice(results.ReadLatencies, func(i, j int) bool { return results.ReadLatencies[i] < results.ReadLatencies[j] })
+	sort.Slice(results.WriteLatencies, func(i, j int) bool { return results.WriteLatencies[i] < results.WriteLatencies[j] })
+	results.MixedReadOps = len(results.ReadLatencies)
+	results.MixedWriteOps = len(results.WriteLatencies)
+
+	return nil
+}
+
+// Runs ops operations of the mixed workload and returns their latencies.
+func (cmd *BenchCommand) runMixedWorker(db *bolt.DB, options *BenchOptions, seed int64, ops int, latest *uint32) (reads, writes []time.Duration, err error) {
+	r := rand.New(rand.NewSource(seed))
+	keySource := newBenchKeySource(options, r, latest)
+
+	for i := 0; i < ops; i++ {
+		key := make([]byte, options.KeySize)
+
+		// Read a single key.
+		if r.Float64() < options.ReadRatio {
+			binary.BigEndian.PutUint32(key, keySource(false))
+
+			t := time.Now()
+			if err := db.View(func(tx *bolt.Tx) error {
+				_ = tx.Bucket(benchBucketName).Get(key)
+				return nil
+			}); err != nil {
+				return nil, nil, err
+			}
+			reads = append(reads, time.Since(t))
+			continue
+		}
+
+		// Write a single key, optionally batched with other workers.
+		binary.BigEndian.PutUint32(key, keySource(true))
+		value := options.newValue(r)
+		fn := func(tx *bolt.Tx) error {
+			b := tx.Bucket(benchBucketName)
+			b.FillPercent = options.FillPercent
+			return b.Put(key, value)
+		}
+
+		t := time.Now()
+		if options.Batch {
+			err = db.Batch(fn)
+		} else {
+			err = db.Update(fn)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		writes = append(writes, time.Since(t))
+	}
+	return reads, writes, nil
+}
+
+// Returns a function choosing the key of each mixed workload operation.
+//
+// The "uniform" distribution chooses every key with the same probability and
+// "zipf" favors lower keys following a Zipfian distribution. Writes update
+// existing keys. The "latest" distribution inserts a new key for each write
+// and favors the most recently inserted keys for reads.
+func newBenchKeySource(options *BenchOptions, r *rand.Rand, latest *uint32) func(write bool) uint32 {
+	n := uint64(options.Iterations)
+	switch options.KeyDist {
+	case "zipf":
+		z := rand.NewZipf(r, 1.1, 1, n-1)
+		return func(bool) uint32 { return 1 + uint32(z.Uint64()) }
+	case "latest":
+		z := rand.NewZipf(r, 1.1, 1, n-1)
+		return func(write bool) uint32 {
+			if write {
+				return atomic.AddUint32(latest, 1)
+			}
+			return atomic.LoadUint32(latest) - uint32(z.Uint64())
+		}
+	default:
+		return func(bool) uint32 { return 1 + uint32(r.Int63n(int64(n))) }
+	}
+}
+
+// Returns a new value with a size chosen from the value size distribution.
+// The "uniform" distribution chooses sizes between 1 and twice ValueSize and
+// "exp" chooses sizes from an exponential distribution. Both have a mean of
+// about ValueSize.
+func (o *BenchOptions) newValue(r *rand.Rand) []byte {
+	if o.ValueSize <= 0 {
+		return []byte{}
+	}
+	switch o.ValueDist {
+	case "uniform":
+		return make([]byte, 1+r.Intn(2*o.ValueSize))
+	case "exp":
+		sz := 1 + int(r.ExpFloat64()*float64(o.ValueSize))
+		if sz > bolt.MaxValueSize {
+			sz = bolt.MaxValueSize
+		}
+		return make([]byte, sz)
+	default:
+		return make([]byte, o.ValueSize)
+	}
+}
+
 // File handlers for the various profiles.
 var cpuprofile, memprofile, blockprofile *os.File
 
@@ -1286,8 +1564,17 @@ type BenchOptions struct {
 	StatsInterval time.Duration
 	FillPercent   float64
 	NoSync        bool
+	PageSize      int
 	Work          bool
 	Path          string
+	Workload      string
+	Ops           int
+	Concurrency   int
+	ReadRatio     float64
+	KeyDist       string
+	ValueDist     string
+	Batch         bool
+	Format        string
 }
 
 // BenchResults represents the performance results of the benchmark.
@@ -1296,6 +1583,13 @@ type BenchResults struct {
 	WriteDuration time.Duration
 	ReadOps       int
 	ReadDuration  time.Duration
+
+	// Results of the mixed workload. The latencies are sorted.
+	MixedReadOps   int
+	MixedWriteOps  int
+	MixedDuration  time.Duration
+	ReadLatencies  []time.Duration
+	WriteLatencies []time.Duration
 }
 
 // Returns the duration for a single write operation.
@@ -1332,6 +1626,143 @@ func (r *BenchResults) ReadOpsPerSecond() int {
 	return int(time.Second) / int(op)
 }
 
+// Returns the duration for a single mixed workload operation.
+func (r *BenchResults) MixedOpDuration() time.Duration {
+	if n := r.MixedReadOps + r.MixedWriteOps; n != 0 {
+		return r.MixedDuration / time.Duration(n)
+	}
+	return 0
+}
+
+// Returns average number of mixed workload operations performed per second,
+// across all workers.
+func (r *BenchResults) MixedOpsPerSecond() int {
+	var op = r.MixedOpDuration()
+	if op == 0 {
+		return 0
+	}
+	return int(time.Second) / int(op)
+}
+
+// Returns the latency below which p percent of the sorted latencies fall.
+func latencyPercentile(latencies []time.Duration, p float64) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+	i := int(float64(len(latencies))*p/100+0.5) - 1
+	if i < 0 {
+		i = 0
+	} else if i >= len(latencies) {
+		i = len(latencies) - 1
+	}
+	return latencies[i]
+}
+
+// benchReport is the output of "bolt bench -format json". Durations are in
+// nanoseconds.
+type benchReport struct {
+	Options struct {
+		Workload    string  `json:"workload"`
+		WriteMode   string  `json:"write_mode"`
+		ReadMode    string  `json:"read_mode,omitempty"`
+		Count       int     `json:"count"`
+		BatchSize   int     `json:"batch_size"`
+		KeySize     int     `json:"key_size"`
+		ValueSize   int     `json:"value_size"`
+		ValueDist   string  `json:"value_dist"`
+		KeyDist     string  `json:"key_dist,omitempty"`
+		Ops         int     `json:"ops,omitempty"`
+		Concurrency int     `json:"concurrency,omitempty"`
+		ReadRatio   float64 `json:"read_ratio,omitempty"`
+		Batch       bool    `json:"batch,omitempty"`
+		FillPercent float64 `json:"fill_percent"`
+		NoSync      bool    `json:"no_sync"`
+		PageSize    int     `json:"page_size"`
+	} `json:"options"`
+
+	Write benchPhaseReport  `json:"write"`
+	Read  *benchPhaseReport `json:"read,omitempty"`
+	Mixed *benchPhaseReport `json:"mixed,omitempty"`
+}
+
+// benchPhaseReport holds the results of a phase of the benchmark.
+type benchPhaseReport struct {
+	Ops          int            `json:"ops"`
+	ReadOps      int            `json:"read_ops,omitempty"`
+	WriteOps     int            `json:"write_ops,omitempty"`
+	Duration     time.Duration  `json:"duration_ns"`
+	OpDuration   time.Duration  `json:"op_duration_ns"`
+	OpsPerSecond int            `json:"ops_per_sec"`
+	ReadLatency  *latencyReport `json:"read_latency,omitempty"`
+	WriteLatency *latencyReport `json:"write_latency,omitempty"`
+}
+
+// latencyReport holds latency percentiles in nanoseconds.
+type latencyReport struct {
+	P50  time.Duration `json:"p50_ns"`
+	P90  time.Duration `json:"p90_ns"`
+	P99  time.Duration `json:"p99_ns"`
+	P999 time.Duration `json:"p999_ns"`
+	Max  time.Duration `json:"max_ns"`
+}
+
+// newLatencyReport returns the percentiles of sorted latencies or nil if
+// there are none.
+func newLatencyReport(latencies []time.Duration) *latencyReport {
+	if len(latencies) == 0 {
+		return nil
+	}
+	return &latencyReport{
+		P50:  latencyPercentile(latencies, 50),
+		P90:  latencyPercentile(latencies, 90),
+		P99:  latencyPercentile(latencies, 99),
+		P999: latencyPercentile(latencies, 99.9),
+		Max:  latencies[len(latencies)-1],
+	}
+}
+
+// newBenchReport returns the JSON report for the results of a benchmark.
+func newBenchReport(options *BenchOptions, r *BenchResults) *benchReport {
+	var rpt benchReport
+	o := &rpt.Options
+	o.Workload, o.WriteMode = options.Workload, options.WriteMode
+	o.Count, o.BatchSize = options.Iterations, options.BatchSize
+	o.KeySize, o.ValueSize, o.ValueDist = options.KeySize, options.ValueSize, options.ValueDist
+	o.FillPercent, o.NoSync, o.PageSize = options.FillPercent, options.NoSync, options.PageSize
+
+	rpt.Write = benchPhaseReport{
+		Ops:          r.WriteOps,
+		Duration:     r.WriteDuration,
+		OpDuration:   r.WriteOpDuration(),
+		OpsPerSecond: r.WriteOpsPerSecond(),
+	}
+
+	if options.Workload != "mixed" {
+		o.ReadMode = options.ReadMode
+		rpt.Read = &benchPhaseReport{
+			Ops:          r.ReadOps,
+			Duration:     r.ReadDuration,
+			OpDuration:   r.ReadOpDuration(),
+			OpsPerSecond: r.ReadOpsPerSecond(),
+		}
+		return &rpt
+	}
+
+	o.KeyDist, o.Ops, o.Concurrency = options.KeyDist, options.Ops, options.Concurrency
+	o.ReadRatio, o.Batch = options.ReadRatio, options.Batch
+	rpt.Mixed = &benchPhaseReport{
+		Ops:          r.MixedReadOps + r.MixedWriteOps,
+		ReadOps:      r.MixedReadOps,
+		WriteOps:     r.MixedWriteOps,
+		Duration:     r.MixedDuration,
+		OpDuration:   r.MixedOpDuration(),
+		OpsPerSecond: r.MixedOpsPerSecond(),
+		ReadLatency:  newLatencyReport(r.ReadLatencies),
+		WriteLatency: newLatencyReport(r.WriteLatencies),
+	}
+	return &rpt
+}
+
 type PageError struct {
 	ID  int
 	Err error
@@ -1437,11 +1868,21 @@ const (
 	leafPageFlag     = 0x02
 	metaPageFlag     = 0x04
 	freelistPageFlag = 0x10
+	prefixPageFlag   = 0x20
 )
 
 // DO NOT EDIT. Copied from the "bolt" package.
 const bucketLeafFlag = 0x01
 
+// DO NOT EDIT. Copied from the "bolt" package.
+const magic uint32 = 0xED0CDAED
+
+// DO NOT EDIT. Copied from the "bolt" package.
+const leafPageElementSize = int(unsafe.Sizeof(leafPageElement{}))
+
+// DO NOT EDIT. Copied from the "bolt" package.
+const prefixHeaderSize = 2
+
 // DO NOT EDIT. Copied from the "bolt" package.
 type pgid uint64
 
@@ -1496,6 +1937,25 @@ func (p *page) leafPageElement(index uint16) *leafPageElement {
 	return n
 }
 
+// DO NOT EDIT. Copied from the "bolt" package.
+func (p *page) keyPrefix() []byte {
+	if (p.flags & prefixPageFlag) == 0 {
+		return nil
+	}
+	ptr := unsafe.Pointer(uintptr(unsafe.Pointer(&p.ptr)) + uintptr(leafPageElementSize*int(p.count)))
+	n := int(*(*uint16)(ptr))
+	return (*[maxAllocSize]byte)(unsafe.Pointer(uintptr(ptr) + prefixHeaderSize))[:n:n]
+}
+
+// DO NOT EDIT. Copied from the "bolt" package.
+func (p *page) leafKey(index uint16) []byte {
+	key := p.leafPageElement(index).key()
+	if prefix := p.keyPrefix(); prefix != nil {
+		key = append(append(make([]byte, 0, len(prefix)+len(key)), prefix...), key...)
+	}
+	return key
+}
+
 // DO NOT EDIT. Copied from the "bolt" package.
 func (p *page) branchPageElement(index uint16) *branchPageElement {
 	return &((*[0x7FFFFFF]branchPageElement)(unsafe.Pointer(&p.ptr)))[index]
@@ -1543,6 +2003,7 @@ type CompactCommand struct {
 	SrcPath   string
 	DstPath   string
 	TxMaxSize int64
+	PageSize  int
 }
 
 // newCompactCommand returns a CompactCommand.
@@ -1561,6 +2022,7 @@ func (cmd *CompactCommand) Run(args ...string) (err error) {
 	fs.SetOutput(ioutil.Discard)
 	fs.StringVar(&cmd.DstPath, "o", "", "")
 	fs.Int64Var(&cmd.TxMaxSize, "tx-max-size", 65536, "")
+	fs.IntVar(&cmd.PageSize, "page-size", 0, "")
 	if err := fs.Parse(args); err == flag.ErrHelp {
 		fmt.Fprintln(cmd.Stderr, cmd.Usage())
 		return ErrUsage
@@ -1592,8 +2054,17 @@ func (cmd *CompactCommand) Run(args ...string) (err error) {
 	}
 	defer src.Close()
 
-	// Open destination database.
-	dst, err := bolt.Open(cmd.DstPath, fi.Mode(), nil)
+	// Open destination database with the source page size unless another
+	// page size is requested. Features of the source are preserved.
+	info := src.Info()
+	pageSize := cmd.PageSize
+	if pageSize == 0 {
+		pageSize = info.PageSize
+	}
+	dst, err := bolt.Open(cmd.DstPath, fi.Mode(), &bolt.Options{
+		PageSize:          pageSize,
+		PrefixCompression: hasFeature(info, "prefix-compression"),
+	})
 	if err != nil {
 		return err
 	}
@@ -1736,5 +2207,2781 @@ Additional options include:
 	-tx-max-size NUM
 		Specifies the maximum size of individual transactions.
 		Defaults to 64KB.
+
+	-page-size NUM
+		Specifies the page size of the new database.
+		Defaults to the page size of the original database.
+`, "\n")
+}
+
+// Format versions that "bolt upgrade" and "bolt downgrade" can write.
+const (
+	minVersion = 2
+	maxVersion = 3
+)
+
+// UpgradeCommand represents the "upgrade" and "downgrade" command execution.
+type UpgradeCommand struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	SrcPath   string
+	DstPath   string
+	Version   int
+	Downgrade bool
+}
+
+// newUpgradeCommand returns an UpgradeCommand.
+func newUpgradeCommand(m *Main, downgrade bool) *UpgradeCommand {
+	return &UpgradeCommand{
+		Stdin:     m.Stdin,
+		Stdout:    m.Stdout,
+		Stderr:    m.Stderr,
+		Downgrade: downgrade,
+	}
+}
+
+// Run executes the command.
+func (cmd *UpgradeCommand) Run(args ...string) error {
+	// Parse flags.
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.StringVar(&cmd.DstPath, "o", "", "")
+	fs.IntVar(&cmd.Version, "version", 0, "")
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		fmt.Fprintln(cmd.Stderr, cmd.Usage())
+		return ErrUsage
+	} else if err != nil {
+		return err
+	} else if cmd.DstPath == "" {
+		return fmt.Errorf("output file required")
+	}
+
+	// Require database paths.
+	cmd.SrcPath = fs.Arg(0)
+	if cmd.SrcPath == "" {
+		return ErrPathRequired
+	}
+
+	// Ensure source file exists.
+	fi, err := os.Stat(cmd.SrcPath)
+	if os.IsNotExist(err) {
+		return ErrFileNotFound
+	} else if err != nil {
+		return err
+	}
+
+	// Open source database.
+	src, err := bolt.Open(cmd.SrcPath, 0444, &bolt.Options{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info := src.Info()
+
+	// Default to the newest or oldest version and check the direction.
+	if cmd.Version == 0 {
+		cmd.Version = maxVersion
+		if cmd.Downgrade {
+			cmd.Version = minVersion
+		}
+	}
+	if cmd.Version < minVersion || cmd.Version > maxVersion {
+		return fmt.Errorf("unsupported version: %d", cmd.Version)
+	} else if cmd.Downgrade && cmd.Version >= info.Version {
+		return fmt.Errorf("cannot downgrade version %d to %d", info.Version, cmd.Version)
+	} else if !cmd.Downgrade && cmd.Version <= info.Version {
+		return fmt.Errorf("cannot upgrade version %d to %d", info.Version, cmd.Version)
+	}
+
+	// Copy into a new database. Version 3 enables every supported feature
+	// while version 2 uses none.
+	dst, err := bolt.Open(cmd.DstPath, fi.Mode(), &bolt.Options{
+		PageSize:          info.PageSize,
+		PrefixCompression: cmd.Version >= 3,
+	})
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	c := &CompactCommand{Stdout: cmd.Stdout, Stderr: cmd.Stderr, TxMaxSize: 65536}
+	if err := c.compact(dst, src); err != nil {
+		return err
+	}
+
+	// The version and flags are only written with a meta page so an empty
+	// database would keep the version it was created with. Commit twice so
+	// that both meta pages are rewritten.
+	for i := 0; i < 2; i++ {
+		if err := dst.Update(func(tx *bolt.Tx) error { return nil }); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(cmd.Stdout, "version %d -> %d\n", info.Version, dst.Info().Version)
+	return nil
+}
+
+// Usage returns the help message.
+func (cmd *UpgradeCommand) Usage() string {
+	if cmd.Downgrade {
+		return strings.TrimLeft(`
+usage: bolt downgrade [options] -o DST SRC
+
+Downgrade copies the database at SRC path to a new database at DST path that
+uses an older format version, so it can be opened by older versions of Bolt.
+Features that the target version does not support are disabled.
+
+The original database is left untouched.
+
+Additional options include:
+
+	-version NUM
+		Specifies the format version to write.
+		Defaults to 2, which every version of Bolt can read.
+`, "\n")
+	}
+
+	return strings.TrimLeft(`
+usage: bolt upgrade [options] -o DST SRC
+
+Upgrade copies the database at SRC path to a new database at DST path that
+uses a newer format version. Version 3 enables prefix compression of leaf
+pages.
+
+The original database is left untouched.
+
+Additional options include:
+
+	-version NUM
+		Specifies the format version to write.
+		Defaults to 3, the newest version.
+`, "\n")
+}
+
+// hasFeature returns true if the database described by info uses a feature.
+func hasFeature(info *bolt.Info, name string) bool {
+	for _, f := range info.Features {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ConvertEndianCommand represents the "convert-endian" command execution.
+type ConvertEndianCommand struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	SrcPath string
+	DstPath string
+}
+
+// newConvertEndianCommand returns a ConvertEndianCommand.
+func newConvertEndianCommand(m *Main) *ConvertEndianCommand {
+	return &ConvertEndianCommand{
+		Stdin:  m.Stdin,
+		Stdout: m.Stdout,
+		Stderr: m.Stderr,
+	}
+}
+
+// Run executes the command.
+func (cmd *ConvertEndianCommand) Run(args ...string) error {
+	// Parse flags.
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.StringVar(&cmd.DstPath, "o", "", "")
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		fmt.Fprintln(cmd.Stderr, cmd.Usage())
+		return ErrUsage
+	} else if err != nil {
+		return err
+	} else if cmd.DstPath == "" {
+		return fmt.Errorf("output file required")
+	}
+
+	// Require database paths.
+	cmd.SrcPath = fs.Arg(0)
+	if cmd.SrcPath == "" {
+		return ErrPathRequired
+	}
+
+	// Open source file. It is read directly since bolt cannot open it.
+	src, err := os.Open(cmd.SrcPath)
+	if os.IsNotExist(err) {
+		return ErrFileNotFound
+	} else if err != nil {
+		return err
+	}
+	defer src.Close()
+	fi, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	// Copy the file and convert the copy in place.
+	dst, err := os.OpenFile(cmd.DstPath, os.O_RDWR|os.O_CREATE|os.O_EXCL, fi.Mode())
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+	if _, err := io.Copy(dst, src); err != nil {
+		return err
+	}
+
+	c := &endianConverter{f: dst}
+	if err := c.convert(); err != nil {
+		return err
+	}
+	if err := dst.Sync(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cmd.Stdout, "%s -> %s\n", orderName(c.from), orderName(c.to))
+	return nil
+}
+
+// Usage returns the help message.
+func (cmd *ConvertEndianCommand) Usage() string {
+	return strings.TrimLeft(`
+usage: bolt convert-endian -o DST SRC
+
+Convert-endian copies the database at SRC path to DST path and swaps the byte
+order of the copy. Bolt stores data in the byte order of the machine that
+wrote it, so this converts a file created on a big endian machine, such as
+s390x, for use on a little endian machine, such as amd64, and vice versa.
+
+Only pages reachable from the latest meta page are converted. Both meta pages
+of the copy point at the converted data.
+
+The original database is left untouched.
+`, "\n")
+}
+
+// endianConverter swaps the byte order of the pages in a data file.
+type endianConverter struct {
+	f        *os.File
+	from, to binary.ByteOrder
+	pageSize int
+}
+
+// orderName returns a readable name for a byte order.
+func orderName(order binary.ByteOrder) string {
+	if order == binary.BigEndian {
+		return "big-endian"
+	}
+	return "little-endian"
+}
+
+// Offsets of fields in a meta page after the page header.
+const (
+	metaMagicOffset    = 0
+	metaPageSizeOffset = 8
+	metaRootOffset     = 16
+	metaFreelistOffset = 32
+	metaTxidOffset     = 48
+	metaChecksumOffset = 56
+)
+
+// convert determines the byte order of the file and converts it.
+func (c *endianConverter) convert() error {
+	// Detect the byte order from the magic of the first meta page.
+	buf := make([]byte, PageHeaderSize+64)
+	if _, err := c.f.ReadAt(buf, 0); err != nil {
+		return err
+	}
+	m := buf[PageHeaderSize:]
+	switch {
+	case binary.LittleEndian.Uint32(m[metaMagicOffset:]) == magic:
+		c.from, c.to = binary.LittleEndian, binary.BigEndian
+	case binary.BigEndian.Uint32(m[metaMagicOffset:]) == magic:
+		c.from, c.to = binary.BigEndian, binary.LittleEndian
+	default:
+		return bolt.ErrInvalid
+	}
+	c.pageSize = int(c.from.Uint32(m[metaPageSizeOffset:]))
+
+	// Use the valid meta page with the highest transaction id.
+	var latest []byte
+	for id := 0; id < 2; id++ {
+		buf := make([]byte, c.pageSize)
+		if _, err := c.f.ReadAt(buf, int64(id*c.pageSize)); err != nil {
+			return err
+		}
+		m := buf[PageHeaderSize:]
+		if c.from.Uint32(m[metaMagicOffset:]) != magic {
+			continue
+		} else if sum := c.from.Uint64(m[metaChecksumOffset:]); sum != 0 && sum != fnv64a(m[:metaChecksumOffset]) {
+			continue
+		} else if latest == nil || c.from.Uint64(m[metaTxidOffset:]) > c.from.Uint64(latest[PageHeaderSize+metaTxidOffset:]) {
+			latest = buf
+		}
+	}
+	if latest == nil {
+		return bolt.ErrInvalid
+	}
+	m = latest[PageHeaderSize:]
+	root := c.from.Uint64(m[metaRootOffset:])
+	freelist := c.from.Uint64(m[metaFreelistOffset:])
+
+	// Convert the data reachable from the meta page.
+	if err := c.convertPage(root); err != nil {
+		return err
+	}
+	if err := c.convertPage(freelist); err != nil {
+		return err
+	}
+
+	// Convert the meta page and write it to both meta page slots.
+	c.swapPageHeader(latest)
+	for _, off := range []int{0, 4, 8, 12, 16, 24, 32, 40, 48} {
+		size := 8
+		if off < 16 {
+			size = 4
+		}
+		reverse(m[off : off+size])
+	}
+	c.to.PutUint64(m[metaChecksumOffset:], fnv64a(m[:metaChecksumOffset]))
+	for id := 0; id < 2; id++ {
+		c.to.PutUint64(latest[0:], uint64(id))
+		if _, err := c.f.WriteAt(latest, int64(id*c.pageSize)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// convertPage converts a page, including its overflow pages, and every page
+// reachable from it.
+func (c *endianConverter) convertPage(id uint64) error {
+	hdr := make([]byte, PageHeaderSize)
+	if _, err := c.f.ReadAt(hdr, int64(id)*int64(c.pageSize)); err != nil {
+		return fmt.Errorf("page %d: %s", id, err)
+	}
+	buf := make([]byte, (int(c.from.Uint32(hdr[12:]))+1)*c.pageSize)
+	if _, err := c.f.ReadAt(buf, int64(id)*int64(c.pageSize)); err != nil {
+		return fmt.Errorf("page %d: %s", id, err)
+	}
+
+	children, err := c.convertPageBuffer(buf)
+	if err != nil {
+		return fmt.Errorf("page %d: %s", id, err)
+	}
+	if _, err := c.f.WriteAt(buf, int64(id)*int64(c.pageSize)); err != nil {
+		return err
+	}
+	for _, child := range children {
+		if err := c.convertPage(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// convertPageBuffer converts a page held in buf and returns the ids of the
+// pages it references.
+func (c *endianConverter) convertPageBuffer(buf []byte) ([]uint64, error) {
+	flags, count := c.from.Uint16(buf[8:]), int(c.from.Uint16(buf[10:]))
+	c.swapPageHeader(buf)
+
+	data := buf[PageHeaderSize:]
+	var children []uint64
+	switch {
+	case flags&branchPageFlag != 0:
+		for i := 0; i < count; i++ {
+			elem := data[i*16:]
+			children = append(children, c.from.Uint64(elem[8:]))
+			reverse(elem[0:4])
+			reverse(elem[4:8])
+			reverse(elem[8:16])
+		}
+
+	case flags&leafPageFlag != 0:
+		for i := 0; i < count; i++ {
+			elem := data[i*16:]
+			eflags, pos := c.from.Uint32(elem[0:]), int(c.from.Uint32(elem[4:]))
+			ksize, vsize := int(c.from.Uint32(elem[8:])), int(c.from.Uint32(elem[12:]))
+			for off := 0; off < 16; off += 4 {
+				reverse(elem[off : off+4])
+			}
+
+			// Convert the header of nested buckets and their inline pages.
+			if eflags&bucketLeafFlag != 0 {
+				value := elem[pos+ksize : pos+ksize+vsize]
+				if root := c.from.Uint64(value); root != 0 {
+					children = append(children, root)
+				} else if _, err := c.convertPageBuffer(value[16:]); err != nil {
+					return nil, err
+				}
+				reverse(value[0:8])
+				reverse(value[8:16])
+			}
+		}
+		if flags&prefixPageFlag != 0 {
+			reverse(data[count*16 : count*16+prefixHeaderSize])
+		}
+
+	case flags&freelistPageFlag != 0:
+		n := count
+		if count == 0xFFFF {
+			n = int(c.from.Uint64(data)) + 1
+		}
+		for i := 0; i < n; i++ {
+			reverse(data[i*8 : i*8+8])
+		}
+
+	default:
+		return nil, fmt.Errorf("unexpected page type: %02x", flags)
+	}
+	return children, nil
+}
+
+// swapPageHeader swaps the byte order of a page header.
+func (c *endianConverter) swapPageHeader(buf []byte) {
+	reverse(buf[0:8])
+	reverse(buf[8:10])
+	reverse(buf[10:12])
+	reverse(buf[12:16])
+}
+
+// reverse reverses b in place.
+func reverse(b []byte) {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+}
+
+// fnv64a returns the FNV-1a hash of b, which bolt uses for meta checksums.
+func fnv64a(b []byte) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write(b)
+	return h.Sum64()
+}
+
+// ExportCommand represents the "export" command execution.
+type ExportCommand struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	Path    string
+	OutPath string
+	Base64  bool
+}
+
+// newExportCommand returns an ExportCommand.
+func newExportCommand(m *Main) *ExportCommand {
+	return &ExportCommand{
+		Stdin:  m.Stdin,
+		Stdout: m.Stdout,
+		Stderr: m.Stderr,
+	}
+}
+
+// Run executes the command.
+func (cmd *ExportCommand) Run(args ...string) error {
+	// Parse flags.
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.StringVar(&cmd.OutPath, "o", "", "")
+	fs.BoolVar(&cmd.Base64, "base64", false, "")
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		fmt.Fprintln(cmd.Stderr, cmd.Usage())
+		return ErrUsage
+	} else if err != nil {
+		return err
+	}
+
+	// Require database path.
+	cmd.Path = fs.Arg(0)
+	if cmd.Path == "" {
+		return ErrPathRequired
+	} else if _, err := os.Stat(cmd.Path); os.IsNotExist(err) {
+		return ErrFileNotFound
+	}
+
+	// Open database.
+	db, err := bolt.Open(cmd.Path, 0666, &bolt.Options{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// Write to stdout unless an output file is specified.
+	if cmd.OutPath == "" {
+		return cmd.export(cmd.Stdout, db)
+	}
+	f, err := os.Create(cmd.OutPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := cmd.export(f, db); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// export writes a record for every bucket and key in db to w. Buckets are
+// written before their contents so the records can be imported in order.
+func (cmd *ExportCommand) export(w io.Writer, db *bolt.DB) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+
+	return (&CompactCommand{}).walk(db, func(keys [][]byte, k, v []byte, seq uint64) error {
+		path := append(append([][]byte{}, keys...), k)
+
+		var r exportRecord
+		if v == nil {
+			r = newExportRecord("bucket", path, nil, nil, cmd.Base64)
+			r.Sequence = seq
+		} else {
+			r = newExportRecord("key", path[:len(path)-1], k, v, cmd.Base64)
+		}
+		return enc.Encode(&r)
+	})
+}
+
+// Usage returns the help message.
+func (cmd *ExportCommand) Usage() string {
+	return strings.TrimLeft(`
+usage: bolt export [options] PATH
+
+Export writes every bucket and key in the database at PATH as JSON Lines,
+one record per line, in key order:
+
+	{"type":"bucket","path":["users"],"sequence":2}
+	{"type":"key","path":["users"],"key":"alice","value":"{\"age\":30}"}
+
+Bucket records hold the full path of the bucket and its sequence. Key records
+hold the path of the bucket that contains the key. The sequence and an empty
+value are omitted. Names, keys and values are written as UTF-8 strings unless
+one of them is not valid UTF-8, in which case the record has an "encoding"
+field of "base64" and all of them are base64 encoded.
+
+The output can be loaded into another database with "bolt import".
+
+Additional options include:
+
+	-o PATH
+		Writes the records to PATH instead of standard output.
+
+	-base64
+		Base64 encodes every record.
+`, "\n")
+}
+
+// exportRecord represents a single line of the "export" and "import" format.
+type exportRecord struct {
+	Type     string   `json:"type"`
+	Path     []string `json:"path"`
+	Key      string   `json:"key,omitempty"`
+	Value    string   `json:"value,omitempty"`
+	Sequence uint64   `json:"sequence,omitempty"`
+	Encoding string   `json:"encoding,omitempty"`
+}
+
+// newExportRecord returns a record for the given bucket path, key and value.
+// The record is base64 encoded if forced or if any field is not valid UTF-8.
+func newExportRecord(typ string, path [][]byte, k, v []byte, forceBase64 bool) exportRecord {
+	var encode func([]byte) string
+	r := exportRecord{Type: typ}
+	r.Encoding, encode = recordEncoding(forceBase64, path, k, v)
+	r.Path = encodePath(path, encode)
+	r.Key, r.Value = encode(k), encode(v)
+	return r
+}
+
+// recordEncoding returns the encoding of a JSON record holding path and
+// values. It is empty, meaning UTF-8, unless base64 is forced or one of the
+// path names or values is not valid UTF-8.
+func recordEncoding(forceBase64 bool, path [][]byte, values ...[]byte) (string, func([]byte) string) {
+	valid := !forceBase64
+	for _, v := range append(values, path...) {
+		valid = valid && utf8.Valid(v)
+	}
+	if !valid {
+		return "base64", base64.StdEncoding.EncodeToString
+	}
+	return "", func(b []byte) string { return string(b) }
+}
+
+// encodePath encodes each name in a bucket path.
+func encodePath(path [][]byte, encode func([]byte) string) []string {
+	a := make([]string, len(path))
+	for i, name := range path {
+		a[i] = encode(name)
+	}
+	return a
+}
+
+// decode returns the raw bucket path, key and value of the record.
+func (r *exportRecord) decode() (path [][]byte, k, v []byte, err error) {
+	decode := func(s string) ([]byte, error) { return []byte(s), nil }
+	switch r.Encoding {
+	case "", "utf8":
+	case "base64":
+		decode = base64.StdEncoding.DecodeString
+	default:
+		return nil, nil, nil, fmt.Errorf("unknown encoding: %q", r.Encoding)
+	}
+
+	path = make([][]byte, len(r.Path))
+	for i, name := range r.Path {
+		if path[i], err = decode(name); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	if k, err = decode(r.Key); err != nil {
+		return nil, nil, nil, err
+	} else if v, err = decode(r.Value); err != nil {
+		return nil, nil, nil, err
+	}
+	return path, k, v, nil
+}
+
+// ImportCommand represents the "import" command execution.
+type ImportCommand struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	Path      string
+	InPath    string
+	TxMaxSize int64
+}
+
+// newImportCommand returns an ImportCommand.
+func newImportCommand(m *Main) *ImportCommand {
+	return &ImportCommand{
+		Stdin:  m.Stdin,
+		Stdout: m.Stdout,
+		Stderr: m.Stderr,
+	}
+}
+
+// Run executes the command.
+func (cmd *ImportCommand) Run(args ...string) error {
+	// Parse flags.
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.Int64Var(&cmd.TxMaxSize, "tx-max-size", 65536, "")
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		fmt.Fprintln(cmd.Stderr, cmd.Usage())
+		return ErrUsage
+	} else if err != nil {
+		return err
+	}
+
+	// Require database path and input file.
+	cmd.Path, cmd.InPath = fs.Arg(0), fs.Arg(1)
+	if cmd.Path == "" {
+		return ErrPathRequired
+	} else if cmd.InPath == "" {
+		return fmt.Errorf("input file required")
+	}
+
+	// Read from stdin if the input file is "-".
+	r := cmd.Stdin
+	if cmd.InPath != "-" {
+		f, err := os.Open(cmd.InPath)
+		if os.IsNotExist(err) {
+			return ErrFileNotFound
+		} else if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	// Open database, creating it if necessary.
+	db, err := bolt.Open(cmd.Path, 0666, nil)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	n, err := cmd.importRecords(db, r)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.Stdout, "%d records imported\n", n)
+	return nil
+}
+
+// importRecords reads records from r and writes them to db. Transactions are
+// committed whenever they exceed TxMaxSize bytes of keys and values.
+func (cmd *ImportCommand) importRecords(db *bolt.DB, r io.Reader) (int, error) {
+	var size int64
+	tx, err := db.Begin(true)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Keep the last bucket so consecutive keys do not walk the path again.
+	// It is only valid until the transaction is committed.
+	var b *bolt.Bucket
+	var bpath [][]byte
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxImportLineSize)
+	n, line := 0, 0
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var rec exportRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return n, fmt.Errorf("line %d: %s", line, err)
+		}
+		path, k, v, err := rec.decode()
+		if err != nil {
+			return n, fmt.Errorf("line %d: %s", line, err)
+		} else if len(path) == 0 {
+			return n, fmt.Errorf("line %d: bucket path required", line)
+		}
+
+		// Commit if the transaction has grown too large.
+		sz := int64(len(k) + len(v))
+		if size+sz > cmd.TxMaxSize && cmd.TxMaxSize != 0 {
+			if err := tx.Commit(); err != nil {
+				return n, err
+			}
+			if tx, err = db.Begin(true); err != nil {
+				return n, err
+			}
+			b, bpath, size = nil, nil, 0
+		}
+		size += sz
+
+		// Create the bucket and any missing parents.
+		if b == nil || !equalPath(path, bpath) {
+			if b, err = tx.CreateBucketPath(path...); err != nil {
+				return n, fmt.Errorf("line %d: %s", line, err)
+			}
+			bpath = path
+		}
+
+		switch rec.Type {
+		case "bucket":
+			err = b.SetSequence(rec.Sequence)
+		case "key":
+			err = b.Put(k, v)
+		default:
+			err = fmt.Errorf("unknown record type: %q", rec.Type)
+		}
+		if err != nil {
+			return n, fmt.Errorf("line %d: %s", line, err)
+		}
+		n++
+	}
+	if err := scanner.Err(); err != nil {
+		return n, fmt.Errorf("line %d: %s", line+1, err)
+	}
+
+	return n, tx.Commit()
+}
+
+// maxImportLineSize is the largest record that "import" can read.
+const maxImportLineSize = 1 << 30
+
+// equalPath returns true if a and b hold the same bucket names.
+func equalPath(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Usage returns the help message.
+func (cmd *ImportCommand) Usage() string {
+	return strings.TrimLeft(`
+usage: bolt import [options] PATH FILE
+
+Import reads the JSON Lines records written by "bolt export" from FILE and
+writes them to the database at PATH, creating it if it does not exist. If FILE
+is "-" then records are read from standard input.
+
+Buckets are created as needed and bucket records set the bucket sequence. Keys
+which already exist in the database are overwritten.
+
+Additional options include:
+
+	-tx-max-size NUM
+		Specifies the maximum size of individual transactions.
+		Defaults to 64KB.
+`, "\n")
+}
+
+// kvOptions represents the options shared by the commands that read and
+// write individual buckets and keys.
+type kvOptions struct {
+	KeyFormat   string
+	ValueFormat string
+	Timeout     time.Duration
+}
+
+// register adds the options to a flag set.
+func (o *kvOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.KeyFormat, "key-format", "utf8", "")
+	fs.StringVar(&o.ValueFormat, "value-format", "utf8", "")
+	fs.DurationVar(&o.Timeout, "timeout", 0, "")
+}
+
+// parseKeys decodes bucket names and keys given on the command line.
+func (o *kvOptions) parseKeys(args []string) ([][]byte, error) {
+	keys := make([][]byte, len(args))
+	for i, arg := range args {
+		k, err := parseBytes(arg, o.KeyFormat)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = k
+	}
+	return keys, nil
+}
+
+// open opens the database at path, waiting up to Timeout for the file lock.
+// Read-only databases are opened with a shared lock.
+func (o *kvOptions) open(path string, readOnly bool) (*bolt.DB, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, ErrFileNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return bolt.Open(path, 0666, &bolt.Options{Timeout: o.Timeout, ReadOnly: readOnly})
+}
+
+// kvOptionsUsage is the help text for the options shared by the commands
+// that read and write individual buckets and keys.
+const kvOptionsUsage = `
+	-key-format FORMAT
+		Format of bucket names and keys: utf8, hex or base64.
+		Defaults to utf8.
+
+	-value-format FORMAT
+		Format of values: utf8, hex or base64. Defaults to utf8.
+
+	-timeout DURATION
+		Time to wait for another process to release the database
+		file lock, such as "5s". Defaults to waiting indefinitely.
+`
+
+// parseBytes decodes s written in the given format.
+func parseBytes(s, format string) ([]byte, error) {
+	switch format {
+	case "utf8":
+		return []byte(s), nil
+	case "hex":
+		return hex.DecodeString(s)
+	case "base64":
+		return base64.StdEncoding.DecodeString(s)
+	default:
+		return nil, fmt.Errorf("unknown format: %s", format)
+	}
+}
+
+// formatBytes encodes b in the given format.
+func formatBytes(b []byte, format string) (string, error) {
+	switch format {
+	case "utf8":
+		return string(b), nil
+	case "hex":
+		return hex.EncodeToString(b), nil
+	case "base64":
+		return base64.StdEncoding.EncodeToString(b), nil
+	default:
+		return "", fmt.Errorf("unknown format: %s", format)
+	}
+}
+
+// BucketsCommand represents the "buckets" command execution.
+type BucketsCommand struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	kvOptions
+}
+
+// newBucketsCommand returns a BucketsCommand.
+func newBucketsCommand(m *Main) *BucketsCommand {
+	return &BucketsCommand{
+		Stdin:  m.Stdin,
+		Stdout: m.Stdout,
+		Stderr: m.Stderr,
+	}
+}
+
+// Run executes the command.
+func (cmd *BucketsCommand) Run(args ...string) error {
+	// Parse flags.
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	cmd.register(fs)
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		fmt.Fprintln(cmd.Stderr, cmd.Usage())
+		return ErrUsage
+	} else if err != nil {
+		return err
+	}
+
+	// Require database path.
+	path := fs.Arg(0)
+	if path == "" {
+		return ErrPathRequired
+	}
+	names, err := cmd.parseKeys(fs.Args()[1:])
+	if err != nil {
+		return err
+	}
+
+	db, err := cmd.open(path, true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.View(func(tx *bolt.Tx) error {
+		// List the root buckets unless a parent bucket is specified.
+		var c *bolt.Cursor
+		if len(names) == 0 {
+			c = tx.Cursor()
+		} else {
+			b, err := tx.BucketPath(names...)
+			if err != nil {
+				return err
+			}
+			c = b.Cursor()
+		}
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if v != nil {
+				continue
+			}
+			s, err := formatBytes(k, cmd.KeyFormat)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.Stdout, s)
+		}
+		return nil
+	})
+}
+
+// Usage returns the help message.
+func (cmd *BucketsCommand) Usage() string {
+	return strings.TrimLeft(`
+usage: bolt buckets [options] PATH [BUCKET...]
+
+Buckets prints the names of the root buckets in the database at PATH, one per
+line. If a bucket path is given then the buckets nested in that bucket are
+printed instead.
+
+Additional options include:
+`+kvOptionsUsage, "\n")
+}
+
+// KeysCommand represents the "keys" command execution.
+type KeysCommand struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	kvOptions
+	Prefix string
+	Start  string
+	End    string
+	Limit  int
+}
+
+// newKeysCommand returns a KeysCommand.
+func newKeysCommand(m *Main) *KeysCommand {
+	return &KeysCommand{
+		Stdin:  m.Stdin,
+		Stdout: m.Stdout,
+		Stderr: m.Stderr,
+	}
+}
+
+// Run executes the command.
+func (cmd *KeysCommand) Run(args ...string) error {
+	// Parse flags.
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	cmd.register(fs)
+	fs.StringVar(&cmd.Prefix, "prefix", "", "")
+	fs.StringVar(&cmd.Start, "start", "", "")
+	fs.StringVar(&cmd.End, "end", "", "")
+	fs.IntVar(&cmd.Limit, "limit", 0, "")
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		fmt.Fprintln(cmd.Stderr, cmd.Usage())
+		return ErrUsage
+	} else if err != nil {
+		return err
+	}
+
+	// Require database path and bucket.
+	path := fs.Arg(0)
+	if path == "" {
+		return ErrPathRequired
+	} else if fs.NArg() < 2 {
+		return ErrBucketRequired
+	}
+	names, err := cmd.parseKeys(fs.Args()[1:])
+	if err != nil {
+		return err
+	}
+
+	// Decode filters.
+	filters, err := cmd.parseKeys([]string{cmd.Prefix, cmd.Start, cmd.End})
+	if err != nil {
+		return err
+	}
+	prefix, start, end := filters[0], filters[1], filters[2]
+	if bytes.Compare(start, prefix) < 0 {
+		start = prefix
+	}
+
+	db, err := cmd.open(path, true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.View(func(tx *bolt.Tx) error {
+		b, err := tx.BucketPath(names...)
+		if err != nil {
+			return err
+		}
+
+		n := 0
+		c := b.Cursor()
+		for k, v := c.Seek(start); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			if len(end) > 0 && bytes.Compare(k, end) >= 0 {
+				break
+			} else if cmd.Limit > 0 && n >= cmd.Limit {
+				break
+			} else if v == nil {
+				continue
+			}
+
+			s, err := formatBytes(k, cmd.KeyFormat)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.Stdout, s)
+			n++
+		}
+		return nil
+	})
+}
+
+// Usage returns the help message.
+func (cmd *KeysCommand) Usage() string {
+	return strings.TrimLeft(`
+usage: bolt keys [options] PATH BUCKET...
+
+Keys prints the keys in a bucket of the database at PATH, one per line, in
+key order. Nested buckets are given as a path of bucket names and are not
+listed; use "bolt buckets" to list them.
+
+Additional options include:
+
+	-prefix KEY
+		Only prints keys beginning with KEY.
+
+	-start KEY
+		Only prints keys greater than or equal to KEY.
+
+	-end KEY
+		Only prints keys less than KEY.
+
+	-limit NUM
+		Prints at most NUM keys.
+`+kvOptionsUsage, "\n")
+}
+
+// GetCommand represents the "get" command execution.
+type GetCommand struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	kvOptions
+}
+
+// newGetCommand returns a GetCommand.
+func newGetCommand(m *Main) *GetCommand {
+	return &GetCommand{
+		Stdin:  m.Stdin,
+		Stdout: m.Stdout,
+		Stderr: m.Stderr,
+	}
+}
+
+// Run executes the command.
+func (cmd *GetCommand) Run(args ...string) error {
+	// Parse flags.
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	cmd.register(fs)
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		fmt.Fprintln(cmd.Stderr, cmd.Usage())
+		return ErrUsage
+	} else if err != nil {
+		return err
+	}
+
+	// Require database path, bucket and key.
+	path := fs.Arg(0)
+	if path == "" {
+		return ErrPathRequired
+	} else if fs.NArg() < 2 {
+		return ErrBucketRequired
+	} else if fs.NArg() < 3 {
+		return ErrKeyRequired
+	}
+	keys, err := cmd.parseKeys(fs.Args()[1:])
+	if err != nil {
+		return err
+	}
+	names, key := keys[:len(keys)-1], keys[len(keys)-1]
+
+	db, err := cmd.open(path, true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.View(func(tx *bolt.Tx) error {
+		b, err := tx.BucketPath(names...)
+		if err != nil {
+			return err
+		}
+
+		v := b.Get(key)
+		if v == nil {
+			if b.Bucket(key) != nil {
+				return bolt.ErrIncompatibleValue
+			}
+			return ErrKeyNotFound
+		}
+
+		s, err := formatBytes(v, cmd.ValueFormat)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.Stdout, s)
+		return nil
+	})
+}
+
+// Usage returns the help message.
+func (cmd *GetCommand) Usage() string {
+	return strings.TrimLeft(`
+usage: bolt get [options] PATH BUCKET... KEY
+
+Get prints the value of KEY in a bucket of the database at PATH. Nested
+buckets are given as a path of bucket names.
+
+Additional options include:
+`+kvOptionsUsage, "\n")
+}
+
+// PutCommand represents the "put" command execution.
+type PutCommand struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	kvOptions
+}
+
+// newPutCommand returns a PutCommand.
+func newPutCommand(m *Main) *PutCommand {
+	return &PutCommand{
+		Stdin:  m.Stdin,
+		Stdout: m.Stdout,
+		Stderr: m.Stderr,
+	}
+}
+
+// Run executes the command.
+func (cmd *PutCommand) Run(args ...string) error {
+	// Parse flags.
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	cmd.register(fs)
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		fmt.Fprintln(cmd.Stderr, cmd.Usage())
+		return ErrUsage
+	} else if err != nil {
+		return err
+	}
+
+	// Require database path, bucket, key and value.
+	path := fs.Arg(0)
+	if path == "" {
+		return ErrPathRequired
+	} else if fs.NArg() < 2 {
+		return ErrBucketRequired
+	} else if fs.NArg() < 3 {
+		return ErrKeyRequired
+	} else if fs.NArg() < 4 {
+		return ErrValueRequired
+	}
+	keys, err := cmd.parseKeys(fs.Args()[1 : fs.NArg()-1])
+	if err != nil {
+		return err
+	}
+	names, key := keys[:len(keys)-1], keys[len(keys)-1]
+	value, err := parseBytes(fs.Arg(fs.NArg()-1), cmd.ValueFormat)
+	if err != nil {
+		return err
+	}
+
+	db, err := cmd.open(path, false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketPath(names...)
+		if err != nil {
+			return err
+		}
+		return b.Put(key, value)
+	})
+}
+
+// Usage returns the help message.
+func (cmd *PutCommand) Usage() string {
+	return strings.TrimLeft(`
+usage: bolt put [options] PATH BUCKET... KEY VALUE
+
+Put sets KEY to VALUE in a bucket of the database at PATH. Nested buckets are
+given as a path of bucket names. Buckets in the path that do not exist are
+created.
+
+Additional options include:
+`+kvOptionsUsage, "\n")
+}
+
+// DeleteCommand represents the "delete" command execution.
+type DeleteCommand struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	kvOptions
+}
+
+// newDeleteCommand returns a DeleteCommand.
+func newDeleteCommand(m *Main) *DeleteCommand {
+	return &DeleteCommand{
+		Stdin:  m.Stdin,
+		Stdout: m.Stdout,
+		Stderr: m.Stderr,
+	}
+}
+
+// Run executes the command.
+func (cmd *DeleteCommand) Run(args ...string) error {
+	// Parse flags.
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	cmd.register(fs)
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		fmt.Fprintln(cmd.Stderr, cmd.Usage())
+		return ErrUsage
+	} else if err != nil {
+		return err
+	}
+
+	// Require database path, bucket and key.
+	path := fs.Arg(0)
+	if path == "" {
+		return ErrPathRequired
+	} else if fs.NArg() < 2 {
+		return ErrBucketRequired
+	} else if fs.NArg() < 3 {
+		return ErrKeyRequired
+	}
+	keys, err := cmd.parseKeys(fs.Args()[1:])
+	if err != nil {
+		return err
+	}
+	names, key := keys[:len(keys)-1], keys[len(keys)-1]
+
+	db, err := cmd.open(path, false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.BucketPath(names...)
+		if err != nil {
+			return err
+		}
+		if k, _ := b.Cursor().Seek(key); !bytes.Equal(k, key) {
+			return ErrKeyNotFound
+		}
+		return b.Delete(key)
+	})
+}
+
+// Usage returns the help message.
+func (cmd *DeleteCommand) Usage() string {
+	return strings.TrimLeft(`
+usage: bolt delete [options] PATH BUCKET... KEY
+
+Delete removes KEY from a bucket of the database at PATH. Nested buckets are
+given as a path of bucket names. Nested buckets cannot be deleted.
+
+Additional options include:
+`+kvOptionsUsage, "\n")
+}
+
+// DiffCommand represents the "diff" command execution.
+type DiffCommand struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	Summary bool
+	Format  string
+}
+
+// newDiffCommand returns a DiffCommand.
+func newDiffCommand(m *Main) *DiffCommand {
+	return &DiffCommand{
+		Stdin:  m.Stdin,
+		Stdout: m.Stdout,
+		Stderr: m.Stderr,
+	}
+}
+
+// Run executes the command.
+func (cmd *DiffCommand) Run(args ...string) error {
+	// Parse flags.
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.BoolVar(&cmd.Summary, "summary", false, "")
+	fs.StringVar(&cmd.Format, "format", "text", "")
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		fmt.Fprintln(cmd.Stderr, cmd.Usage())
+		return ErrUsage
+	} else if err != nil {
+		return err
+	} else if cmd.Format != "text" && cmd.Format != "json" {
+		return fmt.Errorf("unknown format: %s", cmd.Format)
+	}
+
+	// Require both database paths.
+	if fs.NArg() < 2 {
+		return ErrPathRequired
+	}
+	var dbs [2]*bolt.DB
+	for i, path := range fs.Args()[:2] {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return ErrFileNotFound
+		}
+		db, err := bolt.Open(path, 0666, &bolt.Options{ReadOnly: true})
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		dbs[i] = db
+	}
+
+	enc := json.NewEncoder(cmd.Stdout)
+	enc.SetEscapeHTML(false)
+
+	var sum diffSummary
+	if err := dbs[0].View(func(from *bolt.Tx) error {
+		return dbs[1].View(func(to *bolt.Tx) error {
+			return bolt.Diff(from, to, func(d bolt.Difference) error {
+				sum.add(d)
+				if cmd.Summary {
+					return nil
+				} else if cmd.Format == "json" {
+					return enc.Encode(newDiffRecord(d))
+				}
+				fmt.Fprintln(cmd.Stdout, formatDifference(d))
+				return nil
+			})
+		})
+	}); err != nil {
+		return err
+	}
+
+	if !cmd.Summary {
+		return nil
+	} else if cmd.Format == "json" {
+		return enc.Encode(&sum)
+	}
+	fmt.Fprintf(cmd.Stdout, "Buckets: %d added, %d removed, %d sequences modified\n", sum.AddedBuckets, sum.RemovedBuckets, sum.ModifiedSequences)
+	fmt.Fprintf(cmd.Stdout, "Keys: %d added, %d removed, %d modified\n", sum.AddedKeys, sum.RemovedKeys, sum.ModifiedKeys)
+	return nil
+}
+
+// Usage returns the help message.
+func (cmd *DiffCommand) Usage() string {
+	return strings.TrimLeft(`
+usage: bolt diff [options] PATH1 PATH2
+
+Diff compares the database at PATH1 with the database at PATH2 and prints
+the keys and buckets which were added, removed or modified in PATH2, as well
+as buckets whose sequence changed. Buckets are compared in key order. The
+contents of added and removed buckets are not listed.
+
+Additional options include:
+
+	-summary
+		Prints the number of differences of each kind instead of
+		each difference.
+
+	-format FORMAT
+		Output format: text or json. JSON output has a record per
+		line for each difference, using the encoding of "bolt export",
+		or a single record with -summary. Defaults to text.
+`, "\n")
+}
+
+// diffSummary counts the differences between two databases.
+type diffSummary struct {
+	AddedBuckets      int `json:"added_buckets"`
+	RemovedBuckets    int `json:"removed_buckets"`
+	ModifiedSequences int `json:"modified_sequences"`
+	AddedKeys         int `json:"added_keys"`
+	RemovedKeys       int `json:"removed_keys"`
+	ModifiedKeys      int `json:"modified_keys"`
+}
+
+// add counts a difference.
+func (s *diffSummary) add(d bolt.Difference) {
+	switch {
+	case d.Type == bolt.DiffSequence:
+		s.ModifiedSequences++
+	case d.Type == bolt.DiffAdded && d.Bucket:
+		s.AddedBuckets++
+	case d.Type == bolt.DiffRemoved && d.Bucket:
+		s.RemovedBuckets++
+	case d.Type == bolt.DiffAdded:
+		s.AddedKeys++
+	case d.Type == bolt.DiffRemoved:
+		s.RemovedKeys++
+	case d.Type == bolt.DiffModified:
+		s.ModifiedKeys++
+	}
+}
+
+// formatDifference returns a line describing a difference.
+func formatDifference(d bolt.Difference) string {
+	var path []string
+	for _, name := range d.Path {
+		path = append(path, fmt.Sprintf("%q", name))
+	}
+	p := strings.Join(append(path, fmt.Sprintf("%q", d.Key)), "/")
+
+	kind := "key"
+	if d.Bucket {
+		kind = "bucket"
+	}
+	if d.Type == bolt.DiffSequence {
+		return fmt.Sprintf("modified bucket %s sequence: %d -> %d", p, d.OldSequence, d.NewSequence)
+	}
+	return fmt.Sprintf("%s %s %s", d.Type, kind, p)
+}
+
+// diffRecord represents a difference in the JSON output of "diff".
+type diffRecord struct {
+	Type        string   `json:"type"`
+	Bucket      bool     `json:"bucket,omitempty"`
+	Path        []string `json:"path"`
+	Key         string   `json:"key"`
+	OldValue    string   `json:"old_value,omitempty"`
+	NewValue    string   `json:"new_value,omitempty"`
+	OldSequence uint64   `json:"old_sequence,omitempty"`
+	NewSequence uint64   `json:"new_sequence,omitempty"`
+	Encoding    string   `json:"encoding,omitempty"`
+}
+
+// newDiffRecord returns the JSON record for a difference.
+func newDiffRecord(d bolt.Difference) *diffRecord {
+	var encode func([]byte) string
+	r := &diffRecord{
+		Type:        d.Type.String(),
+		Bucket:      d.Bucket,
+		OldSequence: d.OldSequence,
+		NewSequence: d.NewSequence,
+	}
+	r.Encoding, encode = recordEncoding(false, d.Path, d.Key, d.OldValue, d.NewValue)
+	r.Path = encodePath(d.Path, encode)
+	r.Key, r.OldValue, r.NewValue = encode(d.Key), encode(d.OldValue), encode(d.NewValue)
+	return r
+}
+
+// ShellCommand represents the "shell" command execution.
+type ShellCommand struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	Path     string
+	Writable bool
+
+	db   *bolt.DB
+	tx   *bolt.Tx // explicit transaction started with "begin"
+	cwd  [][]byte // path of the current bucket
+	done bool
+}
+
+// newShellCommand returns a ShellCommand.
+func newShellCommand(m *Main) *ShellCommand {
+	return &ShellCommand{
+		Stdin:  m.Stdin,
+		Stdout: m.Stdout,
+		Stderr: m.Stderr,
+	}
+}
+
+// Run executes the command.
+func (cmd *ShellCommand) Run(args ...string) error {
+	// Parse flags.
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.BoolVar(&cmd.Writable, "w", false, "")
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		fmt.Fprintln(cmd.Stderr, cmd.Usage())
+		return ErrUsage
+	} else if err != nil {
+		return err
+	}
+
+	// Require database path.
+	cmd.Path = fs.Arg(0)
+	if cmd.Path == "" {
+		return ErrPathRequired
+	} else if _, err := os.Stat(cmd.Path); os.IsNotExist(err) {
+		return ErrFileNotFound
+	}
+
+	db, err := bolt.Open(cmd.Path, 0666, &bolt.Options{ReadOnly: !cmd.Writable})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	cmd.db = db
+
+	// Execute each line until the input ends or "exit" is entered. Errors
+	// are printed and do not stop the shell.
+	scanner := bufio.NewScanner(cmd.Stdin)
+	for !cmd.done {
+		fmt.Fprint(cmd.Stderr, cmd.prompt())
+		if !scanner.Scan() {
+			break
+		}
+
+		args, err := splitShellArgs(scanner.Text())
+		if err == nil && len(args) > 0 {
+			err = cmd.exec(args[0], args[1:])
+		}
+		if err != nil {
+			fmt.Fprintf(cmd.Stderr, "error: %s\n", err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	// Discard an unfinished transaction.
+	if cmd.tx != nil {
+		fmt.Fprintln(cmd.Stderr, "rolling back open transaction")
+		return cmd.tx.Rollback()
+	}
+	return nil
+}
+
+// prompt returns the prompt showing the current bucket path.
+func (cmd *ShellCommand) prompt() string {
+	var path []string
+	for _, name := range cmd.cwd {
+		path = append(path, shellQuote(name))
+	}
+	prompt := "/" + strings.Join(path, "/")
+	if cmd.tx != nil {
+		prompt += " (tx)"
+	}
+	return prompt + "> "
+}
+
+// exec executes a single shell command.
+func (cmd *ShellCommand) exec(name string, args []string) error {
+	switch name {
+	case "help":
+		fmt.Fprint(cmd.Stdout, shellHelp)
+		return nil
+	case "exit", "quit":
+		cmd.done = true
+		return nil
+	case "begin":
+		return cmd.begin()
+	case "commit":
+		return cmd.commit()
+	case "rollback":
+		return cmd.rollback()
+	case "cd":
+		return cmd.cd(args)
+	case "ls":
+		return cmd.view(cmd.ls)
+	case "stat":
+		return cmd.view(cmd.stat)
+	case "get":
+		if len(args) != 1 {
+			return errors.New("usage: get KEY")
+		}
+		return cmd.view(func(tx *bolt.Tx) error { return cmd.get(tx, []byte(args[0])) })
+	case "seek":
+		if len(args) < 1 || len(args) > 2 {
+			return errors.New("usage: seek KEY [COUNT]")
+		}
+		n := 10
+		if len(args) == 2 {
+			var err error
+			if n, err = strconv.Atoi(args[1]); err != nil {
+				return err
+			}
+		}
+		return cmd.view(func(tx *bolt.Tx) error { return cmd.seek(tx, []byte(args[0]), n) })
+	case "put":
+		if len(args) != 2 {
+			return errors.New("usage: put KEY VALUE")
+		}
+		return cmd.update(func(tx *bolt.Tx) error {
+			b, err := cmd.bucket(tx)
+			if err != nil {
+				return err
+			} else if b == nil {
+				return errors.New("keys cannot be stored in the root; use mkdir")
+			}
+			return b.Put([]byte(args[0]), []byte(args[1]))
+		})
+	case "mkdir":
+		if len(args) != 1 {
+			return errors.New("usage: mkdir BUCKET")
+		}
+		return cmd.update(func(tx *bolt.Tx) error {
+			_, err := tx.CreateBucketPath(append(cmd.path(), []byte(args[0]))...)
+			return err
+		})
+	case "rm":
+		if len(args) != 1 {
+			return errors.New("usage: rm KEY")
+		}
+		return cmd.update(func(tx *bolt.Tx) error { return cmd.rm(tx, []byte(args[0])) })
+	default:
+		return fmt.Errorf("unknown command: %s", name)
+	}
+}
+
+// path returns a copy of the current bucket path.
+func (cmd *ShellCommand) path() [][]byte {
+	return append([][]byte{}, cmd.cwd...)
+}
+
+// bucket returns the current bucket, or nil at the root.
+func (cmd *ShellCommand) bucket(tx *bolt.Tx) (*bolt.Bucket, error) {
+	if len(cmd.cwd) == 0 {
+		return nil, nil
+	}
+	return tx.BucketPath(cmd.cwd...)
+}
+
+// view executes fn in the open transaction or in a new read-only transaction.
+func (cmd *ShellCommand) view(fn func(*bolt.Tx) error) error {
+	if cmd.tx != nil {
+		return fn(cmd.tx)
+	}
+	return cmd.db.View(fn)
+}
+
+// update executes fn in the open transaction or in a new read-write
+// transaction. Returns an error if the database is read-only.
+func (cmd *ShellCommand) update(fn func(*bolt.Tx) error) error {
+	if !cmd.Writable {
+		return errors.New("database is read-only; restart the shell with -w")
+	} else if cmd.tx != nil {
+		return fn(cmd.tx)
+	}
+	return cmd.db.Update(fn)
+}
+
+// begin starts an explicit transaction used by the following commands.
+func (cmd *ShellCommand) begin() error {
+	if cmd.tx != nil {
+		return errors.New("transaction already open")
+	}
+	tx, err := cmd.db.Begin(cmd.Writable)
+	if err != nil {
+		return err
+	}
+	cmd.tx = tx
+	return nil
+}
+
+// commit commits the explicit transaction.
+func (cmd *ShellCommand) commit() error {
+	if cmd.tx == nil {
+		return errors.New("no open transaction")
+	}
+	tx := cmd.tx
+	cmd.tx = nil
+	if !tx.Writable() {
+		return tx.Rollback()
+	}
+	return tx.Commit()
+}
+
+// rollback discards the explicit transaction.
+func (cmd *ShellCommand) rollback() error {
+	if cmd.tx == nil {
+		return errors.New("no open transaction")
+	}
+	tx := cmd.tx
+	cmd.tx = nil
+	return tx.Rollback()
+}
+
+// cd changes the current bucket. Each argument enters a nested bucket, ".."
+// returns to the parent bucket and "/" returns to the root.
+func (cmd *ShellCommand) cd(args []string) error {
+	path := cmd.path()
+	if len(args) == 0 {
+		path = nil
+	}
+	for _, arg := range args {
+		switch arg {
+		case "/":
+			path = nil
+		case "..":
+			if len(path) > 0 {
+				path = path[:len(path)-1]
+			}
+		default:
+			path = append(path, []byte(arg))
+		}
+	}
+
+	// Ensure the bucket exists before changing to it.
+	if len(path) > 0 {
+		if err := cmd.view(func(tx *bolt.Tx) error {
+			_, err := tx.BucketPath(path...)
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+	cmd.cwd = path
+	return nil
+}
+
+// ls prints the keys in the current bucket. Nested buckets end with "/".
+func (cmd *ShellCommand) ls(tx *bolt.Tx) error {
+	return cmd.seek(tx, nil, -1)
+}
+
+// seek prints up to n keys starting at key. A negative n prints every key.
+func (cmd *ShellCommand) seek(tx *bolt.Tx, key []byte, n int) error {
+	b, err := cmd.bucket(tx)
+	if err != nil {
+		return err
+	}
+	c := tx.Cursor()
+	if b != nil {
+		c = b.Cursor()
+	}
+
+	for k, v := c.Seek(key); k != nil && n != 0; k, v = c.Next() {
+		if v == nil {
+			fmt.Fprintf(cmd.Stdout, "%s/\n", shellQuote(k))
+		} else {
+			fmt.Fprintln(cmd.Stdout, shellQuote(k))
+		}
+		n--
+	}
+	return nil
+}
+
+// get prints the value of a key in the current bucket.
+func (cmd *ShellCommand) get(tx *bolt.Tx, key []byte) error {
+	b, err := cmd.bucket(tx)
+	if err != nil {
+		return err
+	} else if b == nil {
+		return ErrKeyNotFound
+	}
+
+	v := b.Get(key)
+	if v == nil {
+		if b.Bucket(key) != nil {
+			return bolt.ErrIncompatibleValue
+		}
+		return ErrKeyNotFound
+	}
+	if isPrintable(string(v)) {
+		fmt.Fprintln(cmd.Stdout, string(v))
+	} else {
+		fmt.Fprintln(cmd.Stdout, strconv.Quote(string(v)))
+	}
+	return nil
+}
+
+// rm deletes a key or a nested bucket from the current bucket.
+func (cmd *ShellCommand) rm(tx *bolt.Tx, key []byte) error {
+	b, err := cmd.bucket(tx)
+	if err != nil {
+		return err
+	} else if b == nil {
+		return tx.DeleteBucket(key)
+	} else if b.Bucket(key) != nil {
+		return b.DeleteBucket(key)
+	} else if k, _ := b.Cursor().Seek(key); !bytes.Equal(k, key) {
+		return ErrKeyNotFound
+	}
+	return b.Delete(key)
+}
+
+// stat prints statistics for the current bucket, or for the database at the
+// root.
+func (cmd *ShellCommand) stat(tx *bolt.Tx) error {
+	b, err := cmd.bucket(tx)
+	if err != nil {
+		return err
+	} else if b == nil {
+		info := cmd.db.Info()
+		fmt.Fprintf(cmd.Stdout, "Page Size: %d\n", info.PageSize)
+		fmt.Fprintf(cmd.Stdout, "Size: %d\n", tx.Size())
+		fmt.Fprintf(cmd.Stdout, "Tx: %d\n", tx.ID())
+		return nil
+	}
+
+	s := b.Stats()
+	fmt.Fprintf(cmd.Stdout, "Keys: %d\n", s.KeyN)
+	fmt.Fprintf(cmd.Stdout, "Buckets: %d\n", s.BucketN-1)
+	fmt.Fprintf(cmd.Stdout, "Depth: %d\n", s.Depth)
+	fmt.Fprintf(cmd.Stdout, "Sequence: %d\n", b.Sequence())
+	fmt.Fprintf(cmd.Stdout, "Branch Pages: %d\n", s.BranchPageN)
+	fmt.Fprintf(cmd.Stdout, "Leaf Pages: %d\n", s.LeafPageN)
+	fmt.Fprintf(cmd.Stdout, "Inline: %v\n", s.BranchPageN == 0 && s.LeafPageN == 0)
+	return nil
+}
+
+// Usage returns the help message.
+func (cmd *ShellCommand) Usage() string {
+	return strings.TrimLeft(`
+usage: bolt shell [options] PATH
+
+Shell opens the database at PATH and reads commands from standard input, one
+per line. Arguments are separated by spaces and may be double quoted using Go
+syntax, such as "a b" or "\x00\xff", which is also how names that are not
+printable are shown.
+`+shellHelp+`
+Additional options include:
+
+	-w
+		Opens the database for writing. By default the database is
+		opened read-only and put, mkdir and rm fail.
+`, "\n")
+}
+
+// shellHelp lists the commands of the shell.
+const shellHelp = `
+Commands:
+
+	cd BUCKET...    enter nested buckets; ".." is the parent, "/" the root
+	ls              list keys in the current bucket; buckets end with "/"
+	seek KEY [N]    list up to N keys starting at KEY; N defaults to 10
+	get KEY         print the value of KEY
+	put KEY VALUE   set the value of KEY
+	mkdir BUCKET    create a nested bucket
+	rm KEY          delete a key or a nested bucket
+	stat            print statistics for the current bucket
+	begin           start a transaction used by the following commands
+	commit          commit the transaction
+	rollback        discard the transaction
+	exit            leave the shell
+`
+
+// splitShellArgs splits a line into arguments separated by spaces. Arguments
+// starting with a double quote are unquoted with Go syntax.
+func splitShellArgs(line string) ([]string, error) {
+	var args []string
+	for {
+		line = strings.TrimLeftFunc(line, unicode.IsSpace)
+		if line == "" {
+			return args, nil
+		}
+
+		// Read unquoted arguments up to the next space.
+		if line[0] != '"' {
+			i := strings.IndexFunc(line, unicode.IsSpace)
+			if i == -1 {
+				i = len(line)
+			}
+			args = append(args, line[:i])
+			line = line[i:]
+			continue
+		}
+
+		// Find the closing quote, skipping escaped characters.
+		i := 1
+		for ; i < len(line) && line[i] != '"'; i++ {
+			if line[i] == '\\' {
+				i++
+			}
+		}
+		if i >= len(line) {
+			return nil, errors.New("unterminated quoted string")
+		}
+		arg, err := strconv.Unquote(line[:i+1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid quoted string: %s", line[:i+1])
+		}
+		args = append(args, arg)
+		line = line[i+1:]
+	}
+}
+
+// shellQuote returns b as is if it can be entered as a shell argument and
+// quoted otherwise.
+func shellQuote(b []byte) string {
+	s := string(b)
+	if s == "" || !isPrintable(s) || strings.ContainsAny(s, " \"") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// ServeCommand represents the "serve" command execution.
+type ServeCommand struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	Path string
+	Addr string
+}
+
+// newServeCommand returns a ServeCommand.
+func newServeCommand(m *Main) *ServeCommand {
+	return &ServeCommand{
+		Stdin:  m.Stdin,
+		Stdout: m.Stdout,
+		Stderr: m.Stderr,
+	}
+}
+
+// Run executes the command.
+func (cmd *ServeCommand) Run(args ...string) error {
+	// Parse flags. They may also follow the database path.
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.StringVar(&cmd.Addr, "addr", "localhost:8080", "")
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		fmt.Fprintln(cmd.Stderr, cmd.Usage())
+		return ErrUsage
+	} else if err != nil {
+		return err
+	}
+	cmd.Path = fs.Arg(0)
+	if fs.NArg() > 1 {
+		if err := fs.Parse(fs.Args()[1:]); err != nil {
+			return err
+		}
+	}
+
+	// Require database path.
+	if cmd.Path == "" {
+		return ErrPathRequired
+	} else if _, err := os.Stat(cmd.Path); os.IsNotExist(err) {
+		return ErrFileNotFound
+	}
+
+	db, err := bolt.Open(cmd.Path, 0666, &bolt.Options{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	fmt.Fprintf(cmd.Stdout, "serving %s on http://%s/\n", cmd.Path, cmd.Addr)
+	return http.ListenAndServe(cmd.Addr, NewHandler(db))
+}
+
+// Usage returns the help message.
+func (cmd *ServeCommand) Usage() string {
+	return strings.TrimLeft(`
+usage: bolt serve [options] PATH
+
+Serve opens the database at PATH read-only and serves an HTML page for
+browsing it along with a JSON API:
+
+	GET /api/info
+		Database information.
+
+	GET /api/buckets?path=NAME...
+		Buckets in the root or in the bucket at path.
+
+	GET /api/keys?path=NAME...&seek=KEY&limit=NUM
+		Keys in a bucket starting at seek. At most limit keys are
+		returned, 100 by default. "next" holds the seek for the next
+		page and is omitted after the last key.
+
+	GET /api/get?path=NAME...&key=KEY
+		Value of a key.
+
+	GET /api/stats?path=NAME...
+		Statistics for a bucket.
+
+Nested buckets are given by repeating the path parameter. Names, keys and
+values are UTF-8 unless the format parameter is set to hex or base64. Keys
+listed as UTF-8 that are not valid UTF-8 are returned in base64 and marked
+with "format" ("next_format" for the next seek).
+
+Additional options include:
+
+	-addr ADDR
+		Address to listen on. Defaults to localhost:8080.
+`, "\n")
+}
+
+// Handler serves a read-only HTTP API and browser for a database.
+type Handler struct {
+	db  *bolt.DB
+	mux *http.ServeMux
+}
+
+// NewHandler returns a new Handler for db.
+func NewHandler(db *bolt.DB) *Handler {
+	h := &Handler{db: db, mux: http.NewServeMux()}
+	h.mux.HandleFunc("/", h.serveIndex)
+	h.mux.HandleFunc("/api/info", h.serveInfo)
+	h.mux.HandleFunc("/api/buckets", h.serveBuckets)
+	h.mux.HandleFunc("/api/keys", h.serveKeys)
+	h.mux.HandleFunc("/api/get", h.serveGet)
+	h.mux.HandleFunc("/api/stats", h.serveStats)
+	return h
+}
+
+// ServeHTTP handles GET and HEAD requests. Other methods are rejected.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		h.writeError(w, errors.New("method not allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) serveIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		h.writeError(w, errors.New("not found"), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = io.WriteString(w, serveIndexHTML)
+}
+
+func (h *Handler) serveInfo(w http.ResponseWriter, r *http.Request) {
+	info, stats := h.db.Info(), h.db.Stats()
+	h.view(w, func(tx *bolt.Tx) (interface{}, error) {
+		return map[string]interface{}{
+			"path":       h.db.Path(),
+			"page_size":  info.PageSize,
+			"version":    info.Version,
+			"features":   info.Features,
+			"size":       tx.Size(),
+			"tx_id":      tx.ID(),
+			"free_pages": stats.FreePageN,
+		}, nil
+	})
+}
+
+func (h *Handler) serveBuckets(w http.ResponseWriter, r *http.Request) {
+	req, err := h.parseRequest(r)
+	if err != nil {
+		h.writeError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	h.view(w, func(tx *bolt.Tx) (interface{}, error) {
+		c := tx.Cursor()
+		if len(req.path) > 0 {
+			b, err := tx.BucketPath(req.path...)
+			if err != nil {
+				return nil, err
+			}
+			c = b.Cursor()
+		}
+
+		buckets := []string{}
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if v == nil {
+				buckets = append(buckets, req.format(k))
+			}
+		}
+		return map[string]interface{}{"buckets": buckets}, nil
+	})
+}
+
+// serveKey represents a key in the response of /api/keys. Format is set when
+// the key had to be encoded differently from the rest of the response.
+type serveKey struct {
+	Key    string `json:"key"`
+	Format string `json:"format,omitempty"`
+	Bucket bool   `json:"bucket,omitempty"`
+	Size   int    `json:"size"`
+}
+
+func (h *Handler) serveKeys(w http.ResponseWriter, r *http.Request) {
+	req, err := h.parseRequest(r)
+	if err != nil {
+		h.writeError(w, err, http.StatusBadRequest)
+		return
+	}
+	seek, err := req.param(r, "seek")
+	if err != nil {
+		h.writeError(w, err, http.StatusBadRequest)
+		return
+	}
+	limit := 100
+	if s := r.Form.Get("limit"); s != "" {
+		if limit, err = strconv.Atoi(s); err != nil || limit <= 0 {
+			h.writeError(w, fmt.Errorf("invalid limit: %s", s), http.StatusBadRequest)
+			return
+		}
+	}
+
+	h.view(w, func(tx *bolt.Tx) (interface{}, error) {
+		b, err := req.bucket(tx)
+		if err != nil {
+			return nil, err
+		}
+
+		keys := []serveKey{}
+		resp := map[string]interface{}{"keys": &keys}
+		c := b.Cursor()
+		for k, v := c.Seek(seek); k != nil; k, v = c.Next() {
+			key, format := req.formatKey(k)
+			if len(keys) == limit {
+				resp["next"] = key
+				if format != "" {
+					resp["next_format"] = format
+				}
+				break
+			}
+			keys = append(keys, serveKey{Key: key, Format: format, Bucket: v == nil, Size: len(v)})
+		}
+		return resp, nil
+	})
+}
+
+func (h *Handler) serveGet(w http.ResponseWriter, r *http.Request) {
+	req, err := h.parseRequest(r)
+	if err != nil {
+		h.writeError(w, err, http.StatusBadRequest)
+		return
+	}
+	key, err := req.param(r, "key")
+	if err != nil {
+		h.writeError(w, err, http.StatusBadRequest)
+		return
+	} else if len(key) == 0 {
+		h.writeError(w, ErrKeyRequired, http.StatusBadRequest)
+		return
+	}
+
+	h.view(w, func(tx *bolt.Tx) (interface{}, error) {
+		b, err := req.bucket(tx)
+		if err != nil {
+			return nil, err
+		}
+		v := b.Get(key)
+		if v == nil {
+			if b.Bucket(key) != nil {
+				return nil, bolt.ErrIncompatibleValue
+			}
+			return nil, ErrKeyNotFound
+		}
+		return map[string]interface{}{"key": req.format(key), "value": req.format(v)}, nil
+	})
+}
+
+func (h *Handler) serveStats(w http.ResponseWriter, r *http.Request) {
+	req, err := h.parseRequest(r)
+	if err != nil {
+		h.writeError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	h.view(w, func(tx *bolt.Tx) (interface{}, error) {
+		b, err := req.bucket(tx)
+		if err != nil {
+			return nil, err
+		}
+		s := b.Stats()
+		return map[string]interface{}{
+			"sequence":              b.Sequence(),
+			"keys":                  s.KeyN,
+			"depth":                 s.Depth,
+			"buckets":               s.BucketN,
+			"inline_buckets":        s.InlineBucketN,
+			"inline_bucket_inuse":   s.InlineBucketInuse,
+			"branch_pages":          s.BranchPageN,
+			"branch_overflow_pages": s.BranchOverflowN,
+			"branch_alloc":          s.BranchAlloc,
+			"branch_inuse":          s.BranchInuse,
+			"leaf_pages":            s.LeafPageN,
+			"leaf_overflow_pages":   s.LeafOverflowN,
+			"leaf_alloc":            s.LeafAlloc,
+			"leaf_inuse":            s.LeafInuse,
+		}, nil
+	})
+}
+
+// view executes fn in a read-only transaction and writes its result as JSON.
+// Missing buckets and keys are reported with a 404 status.
+func (h *Handler) view(w http.ResponseWriter, fn func(tx *bolt.Tx) (interface{}, error)) {
+	var resp interface{}
+	if err := h.db.View(func(tx *bolt.Tx) error {
+		var err error
+		resp, err = fn(tx)
+		return err
+	}); err == ErrKeyNotFound || errors.Is(err, bolt.ErrBucketNotFound) {
+		h.writeError(w, err, http.StatusNotFound)
+		return
+	} else if errors.Is(err, bolt.ErrIncompatibleValue) || err == ErrBucketRequired {
+		h.writeError(w, err, http.StatusBadRequest)
+		return
+	} else if err != nil {
+		h.writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+	h.writeJSON(w, resp, http.StatusOK)
+}
+
+func (h *Handler) writeJSON(w http.ResponseWriter, v interface{}, code int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (h *Handler) writeError(w http.ResponseWriter, err error, code int) {
+	h.writeJSON(w, map[string]string{"error": err.Error()}, code)
+}
+
+// serveRequest holds the parameters shared by the API endpoints.
+type serveRequest struct {
+	path     [][]byte
+	encoding string
+}
+
+// parseRequest parses the format and bucket path parameters of a request.
+func (h *Handler) parseRequest(r *http.Request) (*serveRequest, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	req := &serveRequest{encoding: r.Form.Get("format")}
+	if req.encoding == "" {
+		req.encoding = "utf8"
+	}
+	for _, name := range r.Form["path"] {
+		b, err := parseBytes(name, req.encoding)
+		if err != nil {
+			return nil, err
+		}
+		req.path = append(req.path, b)
+	}
+	return req, nil
+}
+
+// param returns a decoded query parameter.
+func (req *serveRequest) param(r *http.Request, name string) ([]byte, error) {
+	return parseBytes(r.Form.Get(name), req.encoding)
+}
+
+// format encodes a name, key or value for the response.
+func (req *serveRequest) format(b []byte) string {
+	s, _ := formatBytes(b, req.encoding)
+	return s
+}
+
+// formatKey encodes a key for the response. Keys that are not valid UTF-8 are
+// base64 encoded instead, like exported records, and their format is returned.
+func (req *serveRequest) formatKey(k []byte) (string, string) {
+	if req.encoding != "utf8" {
+		return req.format(k), ""
+	}
+	format, encode := recordEncoding(false, nil, k)
+	return encode(k), format
+}
+
+// bucket returns the bucket at the request path.
+func (req *serveRequest) bucket(tx *bolt.Tx) (*bolt.Bucket, error) {
+	if len(req.path) == 0 {
+		return nil, ErrBucketRequired
+	}
+	return tx.BucketPath(req.path...)
+}
+
+// serveIndexHTML is a page for browsing buckets and keys using the API.
+const serveIndexHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>bolt</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+a { cursor: pointer; color: #06c; }
+pre { background: #eee; padding: 1em; white-space: pre-wrap; word-break: break-all; }
+</style>
+</head>
+<body>
+<h1 id="title"></h1>
+<div id="crumbs"></div>
+<ul id="list"></ul>
+<a id="more" hidden>more</a>
+<pre id="value" hidden></pre>
+<script>
+// Names, keys and values are requested in base64 so that binary data is
+// never mangled, and each one is shown as UTF-8 when it is valid or as hex.
+var path = [];
+
+function url(endpoint, p, params) {
+	var q = p.map(function(name) { return "path=" + encodeURIComponent(name); });
+	for (var k in params) { q.push(k + "=" + encodeURIComponent(params[k])); }
+	q.push("format=base64");
+	return "api/" + endpoint + "?" + q.join("&");
+}
+
+function api(endpoint, params) {
+	return fetch(url(endpoint, path, params)).then(function(resp) { return resp.json(); });
+}
+
+function decode(s) {
+	var b = Uint8Array.from(atob(s), function(c) { return c.charCodeAt(0); });
+	try {
+		return {format: "utf8", text: new TextDecoder("utf-8", {fatal: true}).decode(b)};
+	} catch (e) {
+		return {format: "hex", text: Array.from(b, function(x) { return ("0" + x.toString(16)).slice(-2); }).join("")};
+	}
+}
+
+// link returns a link to href labelled with the decoded name. The format
+// the name is shown in is kept in the link.
+function link(name, suffix, href, fn) {
+	var d = decode(name), a = document.createElement("a");
+	a.textContent = (d.format == "hex" ? "0x" : "") + d.text + suffix;
+	a.href = href;
+	a.dataset.format = d.format;
+	a.onclick = function(e) { e.preventDefault(); fn(); };
+	return a;
+}
+
+function item(node) {
+	var li = document.createElement("li");
+	li.appendChild(node);
+	document.getElementById("list").appendChild(li);
+}
+
+function browse(p) {
+	path = p;
+	document.getElementById("list").innerHTML = "";
+	document.getElementById("value").hidden = true;
+	document.getElementById("more").hidden = true;
+
+	var crumbs = document.getElementById("crumbs");
+	crumbs.innerHTML = "";
+	crumbs.appendChild(link("", "/", url("buckets", [], {}), function() { browse([]); }));
+	path.forEach(function(name, i) {
+		var p = path.slice(0, i + 1);
+		crumbs.appendChild(document.createTextNode(" / "));
+		crumbs.appendChild(link(name, "", url("keys", p, {}), function() { browse(p); }));
+	});
+
+	if (path.length == 0) {
+		api("buckets").then(function(resp) {
+			resp.buckets.forEach(function(name) {
+				item(link(name, "/", url("keys", [name], {}), function() { browse([name]); }));
+			});
+		});
+	} else {
+		page("");
+	}
+}
+
+function page(seek) {
+	api("keys", {seek: seek}).then(function(resp) {
+		if (resp.error) { item(document.createTextNode(resp.error)); return; }
+		resp.keys.forEach(function(k) {
+			var p = path.concat([k.key]);
+			if (k.bucket) {
+				item(link(k.key, "/", url("keys", p, {}), function() { browse(p); }));
+			} else {
+				item(link(k.key, " (" + k.size + " bytes)", url("get", path, {key: k.key}), function() { show(k.key); }));
+			}
+		});
+		var more = document.getElementById("more");
+		more.hidden = resp.next === undefined;
+		more.onclick = function() { page(resp.next); };
+	});
+}
+
+function show(key) {
+	api("get", {key: key}).then(function(resp) {
+		var pre = document.getElementById("value");
+		pre.textContent = resp.error || decode(resp.value).text;
+		pre.hidden = false;
+	});
+}
+
+api("info").then(function(resp) { document.getElementById("title").textContent = resp.path; });
+browse([]);
+</script>
+</body>
+</html>
+`
+
+// DuCommand represents the "du" command execution.
+type DuCommand struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	Path   string
+	Sort   string
+	Format string
+}
+
+// newDuCommand returns a DuCommand.
+func newDuCommand(m *Main) *DuCommand {
+	return &DuCommand{
+		Stdin:  m.Stdin,
+		Stdout: m.Stdout,
+		Stderr: m.Stderr,
+	}
+}
+
+// Run executes the command.
+func (cmd *DuCommand) Run(args ...string) error {
+	// Parse flags.
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.StringVar(&cmd.Sort, "sort", "name", "")
+	fs.StringVar(&cmd.Format, "format", "text", "")
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		fmt.Fprintln(cmd.Stderr, cmd.Usage())
+		return ErrUsage
+	} else if err != nil {
+		return err
+	} else if cmd.Sort != "name" && cmd.Sort != "size" {
+		return fmt.Errorf("unknown sort: %s", cmd.Sort)
+	} else if cmd.Format != "text" && cmd.Format != "json" {
+		return fmt.Errorf("unknown format: %s", cmd.Format)
+	}
+
+	// Require database path.
+	cmd.Path = fs.Arg(0)
+	if cmd.Path == "" {
+		return ErrPathRequired
+	} else if _, err := os.Stat(cmd.Path); os.IsNotExist(err) {
+		return ErrFileNotFound
+	}
+
+	db, err := bolt.Open(cmd.Path, 0666, &bolt.Options{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// Measure every bucket.
+	var nodes []*duNode
+	if err := db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			nodes = append(nodes, newDuNode(b, name))
+			return nil
+		})
+	}); err != nil {
+		return err
+	}
+	if cmd.Sort == "size" {
+		sortDuNodes(nodes)
+	}
+
+	if cmd.Format == "json" {
+		enc := json.NewEncoder(cmd.Stdout)
+		enc.SetEscapeHTML(false)
+		return enc.Encode(nodes)
+	}
+
+	w := tabwriter.NewWriter(cmd.Stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "PAGES\tBYTES\tKEYS\tDEPTH\tFILL\t\tBUCKET")
+	var total duNode
+	for _, n := range nodes {
+		n.write(w, 0)
+		total.TotalPages += n.TotalPages
+		total.TotalBytes += n.TotalBytes
+		total.TotalKeys += n.TotalKeys
+	}
+	fmt.Fprintf(w, "%d\t%d\t%d\t\t\t\ttotal\n", total.TotalPages, total.TotalBytes, total.TotalKeys)
+	return w.Flush()
+}
+
+// Usage returns the help message.
+func (cmd *DuCommand) Usage() string {
+	return strings.TrimLeft(`
+usage: bolt du [options] PATH
+
+Du prints the space used by every bucket in the database at PATH as a tree of
+nested buckets. For each bucket it prints:
+
+	PAGES   pages allocated to the bucket and its nested buckets,
+	        including overflow pages
+	BYTES   size of the keys and values in the bucket and its nested
+	        buckets, excluding any page overhead
+	KEYS    number of keys in the bucket and its nested buckets
+	DEPTH   depth of the B+tree of the bucket itself
+	FILL    share of the allocated pages of the bucket itself which is
+	        in use, or "inline" for buckets stored in their parent
+
+The JSON output also holds the values for each bucket without its nested
+buckets and is written as a single array of buckets, each with its nested
+buckets. Names which are not valid UTF-8 are base64 encoded, in which case
+the bucket has an "encoding" field of "base64".
+
+Additional options include:
+
+	-sort ORDER
+		Order of sibling buckets: name or size. Sorting by size lists
+		the buckets with the most pages first. Defaults to name.
+
+	-format FORMAT
+		Output format: text or json. Defaults to text.
+`, "\n")
+}
+
+// duNode represents the space used by a bucket in the output of "du". The
+// Total fields include nested buckets.
+type duNode struct {
+	Name       string    `json:"name"`
+	Encoding   string    `json:"encoding,omitempty"`
+	Keys       int       `json:"keys"`
+	TotalKeys  int       `json:"total_keys"`
+	Bytes      int64     `json:"bytes"`
+	TotalBytes int64     `json:"total_bytes"`
+	Pages      int       `json:"pages"`
+	TotalPages int       `json:"total_pages"`
+	Depth      int       `json:"depth"`
+	Inline     bool      `json:"inline"`
+	Fill       float64   `json:"fill"`
+	Buckets    []*duNode `json:"buckets,omitempty"`
+
+	name  []byte
+	stats bolt.BucketStats // stats including nested buckets
+}
+
+// newDuNode measures b and its nested buckets.
+func newDuNode(b *bolt.Bucket, name []byte) *duNode {
+	var encode func([]byte) string
+	n := &duNode{name: append([]byte{}, name...), stats: b.Stats()}
+	n.Encoding, encode = recordEncoding(false, nil, name)
+	n.Name = encode(name)
+
+	// Count keys and measure nested buckets. The stats of the bucket itself
+	// are found by removing those of its nested buckets.
+	own, subDepth := n.stats, 0
+	c := b.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		if v != nil {
+			n.Keys++
+			n.Bytes += int64(len(k) + len(v))
+			continue
+		}
+
+		child := newDuNode(b.Bucket(k), k)
+		n.Buckets = append(n.Buckets, child)
+		n.TotalKeys += child.TotalKeys
+		n.TotalBytes += child.TotalBytes
+
+		s := child.stats
+		own.BranchPageN -= s.BranchPageN
+		own.BranchOverflowN -= s.BranchOverflowN
+		own.LeafPageN -= s.LeafPageN
+		own.LeafOverflowN -= s.LeafOverflowN
+		own.BranchAlloc -= s.BranchAlloc
+		own.BranchInuse -= s.BranchInuse
+		own.LeafAlloc -= s.LeafAlloc
+		own.LeafInuse -= s.LeafInuse
+		if s.Depth > subDepth {
+			subDepth = s.Depth
+		}
+	}
+	n.TotalKeys += n.Keys
+	n.TotalBytes += n.Bytes
+
+	n.Pages = own.BranchPageN + own.BranchOverflowN + own.LeafPageN + own.LeafOverflowN
+	n.TotalPages = n.stats.BranchPageN + n.stats.BranchOverflowN + n.stats.LeafPageN + n.stats.LeafOverflowN
+	n.Depth = n.stats.Depth - subDepth
+	n.Inline = b.Root() == 0
+	if alloc := own.BranchAlloc + own.LeafAlloc; alloc > 0 {
+		n.Fill = float64(own.BranchInuse+own.LeafInuse) / float64(alloc)
+	}
+	return n
+}
+
+// write prints the bucket and its nested buckets, indented by level.
+func (n *duNode) write(w io.Writer, level int) {
+	fill := "inline"
+	if !n.Inline {
+		fill = fmt.Sprintf("%d%%", int(n.Fill*100))
+	}
+	fmt.Fprintf(w, "%d\t%d\t%d\t%d\t%s\t\t%s%s\n", n.TotalPages, n.TotalBytes, n.TotalKeys, n.Depth, fill, strings.Repeat("  ", level), shellQuote(n.name))
+	for _, child := range n.Buckets {
+		child.write(w, level+1)
+	}
+}
+
+// sortDuNodes sorts buckets at every level so that those with the most
+// pages come first, followed by those with the most bytes.
+func sortDuNodes(nodes []*duNode) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if nodes[i].TotalPages != nodes[j].TotalPages {
+			return nodes[i].TotalPages > nodes[j].TotalPages
+		}
+		return nodes[i].TotalBytes > nodes[j].TotalBytes
+	})
+	for _, n := range nodes {
+		sortDuNodes(n.Buckets)
+	}
+}
+
+// FragCommand represents the "frag" command execution.
+type FragCommand struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// newFragCommand returns a FragCommand.
+func newFragCommand(m *Main) *FragCommand {
+	return &FragCommand{
+		Stdin:  m.Stdin,
+		Stdout: m.Stdout,
+		Stderr: m.Stderr,
+	}
+}
+
+// Run executes the command.
+func (cmd *FragCommand) Run(args ...string) error {
+	// Parse flags.
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	help := fs.Bool("h", false, "")
+	if err := fs.Parse(args); err != nil {
+		return err
+	} else if *help {
+		fmt.Fprintln(cmd.Stderr, cmd.Usage())
+		return ErrUsage
+	}
+
+	// Require database path.
+	path := fs.Arg(0)
+	if path == "" {
+		return ErrPathRequired
+	} else if _, err := os.Stat(path); os.IsNotExist(err) {
+		return ErrFileNotFound
+	}
+
+	db, err := bolt.Open(path, 0666, &bolt.Options{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	s, err := db.FreelistStats()
+	if err != nil {
+		return err
+	}
+	pageSize := db.Info().PageSize
+
+	fmt.Fprintf(cmd.Stdout, "Pages: %d\n", s.PageN)
+	fmt.Fprintf(cmd.Stdout, "Free Pages: %d (%d bytes)\n", s.FreePageN, s.FreePageN*pageSize)
+	fmt.Fprintf(cmd.Stdout, "Pending Pages: %d (%d bytes)\n", s.PendingPageN, s.PendingPageN*pageSize)
+	fmt.Fprintf(cmd.Stdout, "Free Extents: %d\n", s.ExtentN)
+	fmt.Fprintf(cmd.Stdout, "Largest Extent: %d pages\n", s.LargestExtentN)
+	fmt.Fprintf(cmd.Stdout, "Truncatable Tail: %d pages (%d bytes)\n", s.TailFreePageN, s.TailFreePageN*pageSize)
+
+	if s.ExtentN > 0 {
+		fmt.Fprintln(cmd.Stdout, "\nExtent Sizes:")
+		w := tabwriter.NewWriter(cmd.Stdout, 0, 8, 2, ' ', 0)
+		for i, n := range s.ExtentSizes {
+			lo, hi := 1<<uint(i), 1<<uint(i+1)-1
+			if lo == hi {
+				fmt.Fprintf(w, "\t%d\t%d\n", lo, n)
+			} else {
+				fmt.Fprintf(w, "\t%d-%d\t%d\n", lo, hi, n)
+			}
+		}
+		w.Flush()
+	}
+
+	if len(s.Pending) > 0 {
+		fmt.Fprintln(cmd.Stdout, "\nPending Pages by Tx:")
+		w := tabwriter.NewWriter(cmd.Stdout, 0, 8, 2, ' ', 0)
+		for _, p := range s.Pending {
+			fmt.Fprintf(w, "\t%d\t%d\n", p.TxID, p.PageN)
+		}
+		w.Flush()
+	}
+
+	return nil
+}
+
+// Usage returns the help message.
+func (cmd *FragCommand) Usage() string {
+	return strings.TrimLeft(`
+usage: bolt frag PATH
+
+Frag prints how fragmented the free pages of the database at PATH are:
+
+	Pages              pages up to the high water mark
+	Free Pages         pages available for reuse
+	Pending Pages      pages freed by transactions which may still be
+	                   in use by readers
+	Free Extents       runs of contiguous free pages
+	Largest Extent     pages in the longest run, which bounds the size of
+	                   the largest value that can be written without
+	                   growing the file
+	Truncatable Tail   free pages at the end of the data which compaction
+	                   or truncation would give back to the filesystem
+
+It then prints the number of extents by size and the pending pages for each
+transaction that freed them. Many small extents and few pages in the tail
+mean that "bolt compact" is likely to shrink the file.
 `, "\n")
 }
@@ -4,12 +4,16 @@ import (
 	"bytes"
 	crypto "crypto/rand"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/boltdb/bolt"
@@ -288,6 +292,745 @@ func TestCompactCommand_Run(t *testing.T) {
 	}
 }
 
+// Ensure the compact command can change the page size of a database.
+func TestCompactCommand_PageSize(t *testing.T) {
+	db := MustOpen(0666, nil)
+	defer db.Close()
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			return err
+		}
+		return fillBucket(b, []byte("w."))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	db.DB.Close()
+
+	dstPath := db.Path + ".compacted"
+	defer os.Remove(dstPath)
+	m := NewMain()
+	if err := m.Run("compact", "-page-size", "16384", "-o", dstPath, db.Path); err != nil {
+		t.Fatal(err)
+	}
+
+	dst, err := bolt.Open(dstPath, 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if sz := dst.Info().PageSize; sz != 16384 {
+		t.Fatalf("unexpected page size: %d", sz)
+	} else if err := dst.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if src, err := chkdb(db.Path); err != nil {
+		t.Fatal(err)
+	} else if compacted, err := chkdb(dstPath); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(src, compacted) {
+		t.Fatal("the compacted db data isn't the same than the original db")
+	}
+}
+
+// Ensure the upgrade and downgrade commands convert between format versions.
+func TestUpgradeCommand_Run(t *testing.T) {
+	db := MustOpen(0666, nil)
+	defer db.Close()
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			return err
+		}
+		return fillBucket(b, []byte("w."))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	db.DB.Close()
+
+	upPath, downPath := db.Path+".v3", db.Path+".v2"
+	defer os.Remove(upPath)
+	defer os.Remove(downPath)
+
+	m := NewMain()
+	if err := m.Run("downgrade", "-o", downPath, db.Path); err == nil || err.Error() != "cannot downgrade version 2 to 2" {
+		t.Fatalf("unexpected error: %v", err)
+	} else if err := m.Run("upgrade", "-o", upPath, db.Path); err != nil {
+		t.Fatal(err)
+	} else if err := m.Run("downgrade", "-o", downPath, upPath); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		path    string
+		version int
+	}{
+		{upPath, 3},
+		{downPath, 2},
+	} {
+		d, err := bolt.Open(tt.path, 0666, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info := d.Info()
+		d.Close()
+		if info.Version != tt.version || (len(info.Features) == 1) != (tt.version == 3) {
+			t.Fatalf("unexpected info: %d, %v", info.Version, info.Features)
+		}
+	}
+
+	if src, err := chkdb(db.Path); err != nil {
+		t.Fatal(err)
+	} else if dst, err := chkdb(downPath); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(src, dst) {
+		t.Fatal("the converted db data isn't the same than the original db")
+	}
+}
+
+// Ensure that upgrading an empty database writes the new version to both
+// meta pages.
+func TestUpgradeCommand_Run_Empty(t *testing.T) {
+	db := MustOpen(0666, nil)
+	defer db.Close()
+	pageSize := db.Info().PageSize
+	db.DB.Close()
+
+	upPath := db.Path + ".v3"
+	defer os.Remove(upPath)
+
+	m := NewMain()
+	if err := m.Run("upgrade", "-o", upPath, db.Path); err != nil {
+		t.Fatal(err)
+	} else if m.Stdout.String() != "version 2 -> 3\n" {
+		t.Fatalf("unexpected stdout: %q", m.Stdout.String())
+	}
+
+	d, err := bolt.Open(upPath, 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := d.Info()
+	d.Close()
+	if info.Version != 3 || len(info.Features) != 1 {
+		t.Fatalf("unexpected info: %d, %v", info.Version, info.Features)
+	}
+
+	// The version follows the 16 byte page header and the 4 byte magic.
+	buf, err := ioutil.ReadFile(upPath)
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(buf[20:24], buf[pageSize+20:pageSize+24]) {
+		t.Fatalf("unexpected meta versions: %x, %x", buf[20:24], buf[pageSize+20:pageSize+24])
+	}
+}
+
+// Ensure the convert-endian command swaps the byte order of a database and
+// that converting it back restores the original data.
+func TestConvertEndianCommand_Run(t *testing.T) {
+	db := MustOpen(0666, &bolt.Options{PrefixCompression: true})
+	defer db.Close()
+	if err := db.Update(func(tx *bolt.Tx) error {
+		for i := 0; i < 3; i++ {
+			b, err := tx.CreateBucket([]byte(fmt.Sprintf("b%d", i)))
+			if err != nil {
+				return err
+			} else if err := b.SetSequence(uint64(i + 1)); err != nil {
+				return err
+			} else if err := fillBucket(b, []byte(fmt.Sprintf("b%d.", i))); err != nil {
+				return err
+			}
+		}
+		b, err := tx.CreateBucket([]byte("large"))
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 1000; i++ {
+			if err := b.Put([]byte(fmt.Sprintf("%04d", i)), make([]byte, 100+i)); err != nil {
+				return err
+			}
+		}
+		return b.Put([]byte("overflow"), make([]byte, 20000))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	db.DB.Close()
+
+	swapped, restored := db.Path+".swapped", db.Path+".restored"
+	defer os.Remove(swapped)
+	defer os.Remove(restored)
+
+	m := NewMain()
+	if err := m.Run("convert-endian", "-o", swapped, db.Path); err != nil {
+		t.Fatal(err)
+	} else if _, err := bolt.Open(swapped, 0666, nil); err != bolt.ErrForeignEndian {
+		t.Fatalf("unexpected error: %v", err)
+	} else if err := m.Run("convert-endian", "-o", restored, swapped); err != nil {
+		t.Fatal(err)
+	}
+
+	if src, err := chkdb(db.Path); err != nil {
+		t.Fatal(err)
+	} else if dst, err := chkdb(restored); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(src, dst) {
+		t.Fatal("the restored db data isn't the same than the original db")
+	}
+
+	// Ensure the restored file is consistent.
+	if err := m.Run("check", restored); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure the export command writes readable records for UTF-8 data and
+// base64 records for binary data.
+func TestExportCommand_Run(t *testing.T) {
+	db := MustOpen(0666, nil)
+	defer db.Close()
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("users"))
+		if err != nil {
+			return err
+		} else if err := b.SetSequence(2); err != nil {
+			return err
+		} else if err := b.Put([]byte("alice"), []byte(`{"age":30}`)); err != nil {
+			return err
+		} else if err := b.Put([]byte("bob"), []byte{}); err != nil {
+			return err
+		}
+		b, err = b.CreateBucket([]byte{0xFF})
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte("k"), []byte("v"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	db.DB.Close()
+
+	m := NewMain()
+	if err := m.Run("export", db.Path); err != nil {
+		t.Fatal(err)
+	}
+	exp := `{"type":"bucket","path":["users"],"sequence":2}
+{"type":"key","path":["users"],"key":"alice","value":"{\"age\":30}"}
+{"type":"key","path":["users"],"key":"bob"}
+{"type":"bucket","path":["dXNlcnM=","/w=="],"encoding":"base64"}
+{"type":"key","path":["dXNlcnM=","/w=="],"key":"aw==","value":"dg==","encoding":"base64"}
+`
+	if s := m.Stdout.String(); s != exp {
+		t.Fatalf("unexpected stdout:\n%s", s)
+	}
+}
+
+// Ensure that a database can be exported and imported without changes.
+func TestImportCommand_Run(t *testing.T) {
+	db := MustOpen(0666, nil)
+	defer db.Close()
+	if err := db.Update(func(tx *bolt.Tx) error {
+		for i := 0; i < 3; i++ {
+			b, err := tx.CreateBucket([]byte(fmt.Sprintf("b%d", i)))
+			if err != nil {
+				return err
+			} else if err := b.SetSequence(uint64(i * 100)); err != nil {
+				return err
+			} else if err := fillBucket(b, []byte(fmt.Sprintf("b%d.", i))); err != nil {
+				return err
+			}
+			if nb := b.Bucket([]byte(fmt.Sprintf("b%d.b0", i))); nb != nil {
+				if err := nb.SetSequence(uint64(i + 1)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	db.DB.Close()
+
+	out, dst := db.Path+".jsonl", db.Path+".imported"
+	defer os.Remove(out)
+	defer os.Remove(dst)
+
+	m := NewMain()
+	if err := m.Run("export", "-o", out, db.Path); err != nil {
+		t.Fatal(err)
+	} else if err := m.Run("import", "-tx-max-size", "512", dst, out); err != nil {
+		t.Fatal(err)
+	}
+
+	if src, err := chkdb(db.Path); err != nil {
+		t.Fatal(err)
+	} else if dst, err := chkdb(dst); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(src, dst) {
+		t.Fatal("the imported db data isn't the same than the original db")
+	}
+}
+
+// Ensure the import command reports the line of an invalid record.
+func TestImportCommand_Run_ErrInvalidRecord(t *testing.T) {
+	db := MustOpen(0666, nil)
+	defer db.Close()
+	db.DB.Close()
+
+	m := NewMain()
+	m.Stdin.WriteString(`{"type":"bucket","path":["a"]}` + "\n" + `{"type":"key","path":[],"key":"k"}` + "\n")
+	if err := m.Run("import", db.Path, "-"); err == nil || err.Error() != "line 2: bucket path required" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure the put, get, keys, buckets and delete commands work on nested
+// buckets.
+func TestPutCommand_Run(t *testing.T) {
+	db := MustOpen(0666, nil)
+	defer db.Close()
+	db.DB.Close()
+
+	m := NewMain()
+	for _, args := range [][]string{
+		{"put", db.Path, "users", "admins", "alice", "1"},
+		{"put", db.Path, "users", "admins", "bob", "2"},
+		{"put", db.Path, "users", "admins", "carol", "3"},
+		{"put", db.Path, "users", "members", "dave", "4"},
+		{"put", "-key-format", "hex", "-value-format", "base64", db.Path, "7573657273", "00ff", "AAE="},
+		{"delete", db.Path, "users", "admins", "bob"},
+	} {
+		if err := m.Run(args...); err != nil {
+			t.Fatalf("%v: %s", args, err)
+		}
+	}
+
+	for _, tt := range []struct {
+		args []string
+		exp  string
+	}{
+		{[]string{"buckets", db.Path}, "users\n"},
+		{[]string{"buckets", db.Path, "users"}, "admins\nmembers\n"},
+		{[]string{"keys", db.Path, "users", "admins"}, "alice\ncarol\n"},
+		{[]string{"keys", "-prefix", "c", db.Path, "users", "admins"}, "carol\n"},
+		{[]string{"keys", "-start", "b", "-end", "d", db.Path, "users", "admins"}, "carol\n"},
+		{[]string{"keys", "-limit", "1", db.Path, "users", "admins"}, "alice\n"},
+		{[]string{"keys", "-key-format", "hex", db.Path, "7573657273"}, "00ff\n"},
+		{[]string{"get", db.Path, "users", "admins", "carol"}, "3\n"},
+		{[]string{"get", "-key-format", "base64", "-value-format", "hex", db.Path, "dXNlcnM=", "AP8="}, "0001\n"},
+	} {
+		m := NewMain()
+		if err := m.Run(tt.args...); err != nil {
+			t.Fatalf("%v: %s", tt.args, err)
+		} else if s := m.Stdout.String(); s != tt.exp {
+			t.Fatalf("%v: unexpected stdout: %q", tt.args, s)
+		}
+	}
+
+	if err := m.Run("get", db.Path, "users", "admins", "bob"); err != main.ErrKeyNotFound {
+		t.Fatalf("unexpected error: %v", err)
+	} else if err := m.Run("delete", db.Path, "users", "admins", "bob"); err != main.ErrKeyNotFound {
+		t.Fatalf("unexpected error: %v", err)
+	} else if err := m.Run("get", db.Path, "users", "nobody", "bob"); err == nil || err.Error() != `bucket "users"/"nobody": bucket not found` {
+		t.Fatalf("unexpected error: %v", err)
+	} else if err := m.Run("get", db.Path, "users"); err != main.ErrKeyRequired {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure the get command gives up waiting for a locked database.
+func TestGetCommand_Run_Timeout(t *testing.T) {
+	db := MustOpen(0666, nil)
+	defer db.Close()
+
+	m := NewMain()
+	if err := m.Run("get", "-timeout", "100ms", db.Path, "users", "alice"); err != bolt.ErrTimeout {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure the diff command prints the differences between two databases.
+func TestDiffCommand_Run(t *testing.T) {
+	a, b := MustOpen(0666, nil), MustOpen(0666, nil)
+	defer a.Close()
+	defer b.Close()
+	a.DB.Close()
+	b.DB.Close()
+
+	m := NewMain()
+	for _, args := range [][]string{
+		{"put", a.Path, "users", "alice", "1"},
+		{"put", a.Path, "users", "bob", "2"},
+		{"put", a.Path, "old", "x", "1"},
+		{"put", b.Path, "users", "alice", "10"},
+		{"put", b.Path, "users", "admins", "carol", "3"},
+		{"put", "-value-format", "hex", b.Path, "users", "dave", "ff"},
+	} {
+		if err := m.Run(args...); err != nil {
+			t.Fatalf("%v: %s", args, err)
+		}
+	}
+
+	for _, tt := range []struct {
+		args []string
+		exp  string
+	}{
+		{[]string{"diff", a.Path, b.Path}, `removed bucket "old"
+added bucket "users"/"admins"
+modified key "users"/"alice"
+removed key "users"/"bob"
+added key "users"/"dave"
+`},
+		{[]string{"diff", "-summary", a.Path, b.Path}, `Buckets: 1 added, 1 removed, 0 sequences modified
+Keys: 1 added, 1 removed, 1 modified
+`},
+		{[]string{"diff", "-summary", "-format", "json", a.Path, b.Path}, `{"added_buckets":1,"removed_buckets":1,"modified_sequences":0,"added_keys":1,"removed_keys":1,"modified_keys":1}
+`},
+		{[]string{"diff", "-format", "json", b.Path, a.Path}, `{"type":"added","bucket":true,"path":[],"key":"old"}
+{"type":"removed","bucket":true,"path":["users"],"key":"admins"}
+{"type":"modified","path":["users"],"key":"alice","old_value":"10","new_value":"1"}
+{"type":"added","path":["users"],"key":"bob","new_value":"2"}
+{"type":"removed","path":["dXNlcnM="],"key":"ZGF2ZQ==","old_value":"/w==","encoding":"base64"}
+`},
+		{[]string{"diff", a.Path, a.Path}, ""},
+	} {
+		m := NewMain()
+		if err := m.Run(tt.args...); err != nil {
+			t.Fatalf("%v: %s", tt.args, err)
+		} else if s := m.Stdout.String(); s != tt.exp {
+			t.Fatalf("%v: unexpected stdout:\n%s", tt.args, s)
+		}
+	}
+}
+
+// Ensure the shell command executes a script of commands.
+func TestShellCommand_Run(t *testing.T) {
+	db := MustOpen(0666, nil)
+	defer db.Close()
+	db.DB.Close()
+
+	m := NewMain()
+	m.Stdin.WriteString(`mkdir users
+cd users
+put alice 1
+put "b o b" "\x00\x01"
+mkdir admins
+begin
+put carol 3
+rm alice
+rollback
+begin
+put dave 4
+commit
+ls
+get "b o b"
+get alice
+seek c 1
+cd admins
+cd ..
+rm admins
+cd /
+ls
+bogus
+`)
+	if err := m.Run("shell", "-w", db.Path); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := `admins/
+alice
+"b o b"
+dave
+"\x00\x01"
+1
+dave
+users/
+`
+	if s := m.Stdout.String(); s != exp {
+		t.Fatalf("unexpected stdout:\n%s", s)
+	} else if s := m.Stderr.String(); !strings.Contains(s, "/users (tx)> ") || !strings.Contains(s, "error: unknown command: bogus") {
+		t.Fatalf("unexpected stderr:\n%s", s)
+	}
+
+	// Ensure the database is read-only unless -w is passed.
+	m = NewMain()
+	m.Stdin.WriteString("cd users\nput x y\nget dave\n")
+	if err := m.Run("shell", db.Path); err != nil {
+		t.Fatal(err)
+	} else if s := m.Stdout.String(); s != "4\n" {
+		t.Fatalf("unexpected stdout: %q", s)
+	} else if s := m.Stderr.String(); !strings.Contains(s, "error: database is read-only") {
+		t.Fatalf("unexpected stderr:\n%s", s)
+	}
+}
+
+// Ensure the serve command handler serves the JSON API.
+func TestHandler(t *testing.T) {
+	db := MustOpen(0666, nil)
+	defer db.Close()
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("users"))
+		if err != nil {
+			return err
+		}
+		for _, k := range []string{"alice", "bob", "carol"} {
+			if err := b.Put([]byte(k), []byte(k+"!")); err != nil {
+				return err
+			}
+		}
+		if _, err := b.CreateBucket([]byte("admins")); err != nil {
+			return err
+		}
+
+		// Keys which are not valid UTF-8.
+		b, err = tx.CreateBucket([]byte("bin"))
+		if err != nil {
+			return err
+		}
+		for _, k := range []string{"\xfe", "\xff"} {
+			if err := b.Put([]byte(k), []byte("x")); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	db.DB.Close()
+
+	rodb, err := bolt.Open(db.Path, 0666, &bolt.Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rodb.Close()
+
+	s := httptest.NewServer(main.NewHandler(rodb))
+	defer s.Close()
+
+	for _, tt := range []struct {
+		method string
+		url    string
+		code   int
+		exp    string
+	}{
+		{"GET", "/api/buckets", 200, `{"buckets":["bin","users"]}`},
+		{"GET", "/api/buckets?path=users", 200, `{"buckets":["admins"]}`},
+		{"GET", "/api/keys?path=users&limit=2", 200, `{"keys":[{"key":"admins","bucket":true,"size":0},{"key":"alice","size":6}],"next":"bob"}`},
+		{"GET", "/api/keys?path=users&seek=bob", 200, `{"keys":[{"key":"bob","size":4},{"key":"carol","size":6}]}`},
+		{"GET", "/api/keys?path=bin&limit=1", 200, `{"keys":[{"key":"/g==","format":"base64","size":1}],"next":"/w==","next_format":"base64"}`},
+		{"GET", "/api/keys?path=62696e&format=hex", 200, `{"keys":[{"key":"fe","size":1},{"key":"ff","size":1}]}`},
+		{"GET", "/api/keys?path=users&limit=0", 400, `{"error":"invalid limit: 0"}`},
+		{"GET", "/api/get?path=users&key=bob", 200, `{"key":"bob","value":"bob!"}`},
+		{"GET", "/api/get?path=users&key=Ym9i&format=base64&path=dXNlcnM%3D", 400, `{"error":"illegal base64 data at input byte 4"}`},
+		{"GET", "/api/get?path=dXNlcnM%3D&key=Ym9i&format=base64", 200, `{"key":"Ym9i","value":"Ym9iIQ=="}`},
+		{"GET", "/api/get?path=users&key=dave", 404, `{"error":"key not found"}`},
+		{"GET", "/api/get?path=nobody&key=dave", 404, `{"error":"bucket \"nobody\": bucket not found"}`},
+		{"GET", "/api/stats", 400, `{"error":"bucket required"}`},
+		{"POST", "/api/get?path=users&key=bob", 405, `{"error":"method not allowed"}`},
+		{"GET", "/missing", 404, `{"error":"not found"}`},
+	} {
+		req, _ := http.NewRequest(tt.method, s.URL+tt.url, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tt.code {
+			t.Fatalf("%s: unexpected status: %d", tt.url, resp.StatusCode)
+		} else if strings.TrimSpace(string(body)) != tt.exp {
+			t.Fatalf("%s: unexpected body: %s", tt.url, body)
+		}
+	}
+
+	// Ensure the stats and info endpoints and the browser page respond.
+	for _, url := range []string{"/api/stats?path=users", "/api/info", "/"} {
+		resp, err := http.Get(s.URL + url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != 200 {
+			t.Fatalf("%s: unexpected status: %d: %s", url, resp.StatusCode, body)
+		} else if url == "/api/stats?path=users" && !strings.Contains(string(body), `"keys":4`) {
+			t.Fatalf("unexpected stats: %s", body)
+		} else if url == "/" && !strings.Contains(string(body), `"format=base64"`) {
+			t.Fatalf("expected page to request base64: %s", body)
+		}
+	}
+}
+
+// Ensure the bench command runs a concurrent mixed workload and reports its
+// results as JSON.
+func TestBenchCommand_Mixed(t *testing.T) {
+	for _, dist := range []string{"uniform", "zipf", "latest"} {
+		m := NewMain()
+		if err := m.Run("bench", "-count", "500", "-no-sync", "-workload", "mixed", "-ops", "400",
+			"-concurrency", "4", "-read-ratio", "0.5", "-key-dist", dist, "-value-dist", "exp", "-format", "json"); err != nil {
+			t.Fatal(err)
+		}
+
+		var rpt struct {
+			Write struct{ Ops int }
+			Read  *struct{}
+			Mixed struct {
+				Ops          int
+				ReadOps      int                       `json:"read_ops"`
+				WriteOps     int                       `json:"write_ops"`
+				ReadLatency  *struct{ P50, Max int64 } `json:"read_latency"`
+				WriteLatency *struct{ P50, Max int64 } `json:"write_latency"`
+			}
+		}
+		if err := json.Unmarshal(m.Stdout.Bytes(), &rpt); err != nil {
+			t.Fatal(err)
+		} else if rpt.Write.Ops != 500 || rpt.Read != nil {
+			t.Fatalf("unexpected report: %s", m.Stdout.String())
+		} else if rpt.Mixed.Ops != 400 || rpt.Mixed.ReadOps+rpt.Mixed.WriteOps != 400 {
+			t.Fatalf("unexpected report: %s", m.Stdout.String())
+		} else if rpt.Mixed.ReadLatency == nil || rpt.Mixed.WriteLatency == nil {
+			t.Fatalf("expected latencies: %s", m.Stdout.String())
+		}
+	}
+
+	if err := NewMain().Run("bench", "-workload", "mixed", "-write-mode", "rnd"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+// Ensure the du command reports the space used by each nested bucket.
+func TestDuCommand_Run(t *testing.T) {
+	db := MustOpen(0666, nil)
+	defer db.Close()
+	if err := db.Update(func(tx *bolt.Tx) error {
+		small, err := tx.CreateBucket([]byte("small"))
+		if err != nil {
+			return err
+		} else if err := small.Put([]byte("k"), []byte("v")); err != nil {
+			return err
+		}
+
+		large, err := tx.CreateBucket([]byte("large"))
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 1000; i++ {
+			if err := large.Put([]byte(fmt.Sprintf("%04d", i)), make([]byte, 96)); err != nil {
+				return err
+			}
+		}
+		nested, err := large.CreateBucket([]byte("nested"))
+		if err != nil {
+			return err
+		}
+		return nested.Put([]byte("a"), []byte("bc"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	db.DB.Close()
+
+	m := NewMain()
+	if err := m.Run("du", "-format", "json", "-sort", "size", db.Path); err != nil {
+		t.Fatal(err)
+	}
+	var nodes []struct {
+		Name       string
+		Keys       int
+		TotalKeys  int   `json:"total_keys"`
+		TotalBytes int64 `json:"total_bytes"`
+		Pages      int
+		TotalPages int `json:"total_pages"`
+		Depth      int
+		Inline     bool
+		Fill       float64
+		Buckets    []struct {
+			Name   string
+			Keys   int
+			Pages  int
+			Inline bool
+		}
+	}
+	if err := json.Unmarshal(m.Stdout.Bytes(), &nodes); err != nil {
+		t.Fatal(err)
+	} else if len(nodes) != 2 || nodes[0].Name != "large" || nodes[1].Name != "small" {
+		t.Fatalf("unexpected buckets: %s", m.Stdout.String())
+	}
+
+	large := nodes[0]
+	if large.Keys != 1000 || large.TotalKeys != 1001 || large.TotalBytes != 100003 {
+		t.Fatalf("unexpected keys: %s", m.Stdout.String())
+	} else if large.Pages < 25 || large.Pages != large.TotalPages || large.Depth != 2 || large.Inline {
+		t.Fatalf("unexpected pages: %s", m.Stdout.String())
+	} else if large.Fill <= 0 || large.Fill > 1 {
+		t.Fatalf("unexpected fill: %v", large.Fill)
+	} else if len(large.Buckets) != 1 || large.Buckets[0].Name != "nested" || !large.Buckets[0].Inline || large.Buckets[0].Keys != 1 {
+		t.Fatalf("unexpected nested bucket: %s", m.Stdout.String())
+	}
+
+	// Ensure the text output lists nested buckets below their parent.
+	m = NewMain()
+	if err := m.Run("du", db.Path); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(m.Stdout.String()), "\n")
+	if len(lines) != 5 || !strings.HasSuffix(lines[1], "  large") || !strings.HasSuffix(lines[2], "inline    nested") || !strings.HasSuffix(lines[3], "  small") || !strings.HasSuffix(lines[4], "total") {
+		t.Fatalf("unexpected stdout:\n%s", m.Stdout.String())
+	}
+}
+
+// Ensure the "frag" command reports the free extents left by a deleted bucket.
+func TestFragCommand_Run(t *testing.T) {
+	db := MustOpen(0666, nil)
+	defer db.Close()
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 1000; i++ {
+			if err := b.Put([]byte(fmt.Sprintf("%04d", i)), make([]byte, 100)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		return tx.DeleteBucket([]byte("widgets"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucket([]byte("gadgets"))
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	s, err := db.FreelistStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.DB.Close()
+
+	m := NewMain()
+	if err := m.Run("frag", db.Path); err != nil {
+		t.Fatal(err)
+	}
+	for _, exp := range []string{
+		fmt.Sprintf("Pages: %d\n", s.PageN),
+		// Pending pages are free once the database is reopened.
+		fmt.Sprintf("Free Pages: %d (%d bytes)\n", s.FreePageN+s.PendingPageN, (s.FreePageN+s.PendingPageN)*os.Getpagesize()),
+		fmt.Sprintf("Largest Extent: %d pages\n", s.LargestExtentN),
+		"\nExtent Sizes:\n",
+	} {
+		if !strings.Contains(m.Stdout.String(), exp) {
+			t.Fatalf("expected %q in stdout:\n%s", exp, m.Stdout.String())
+		}
+	}
+	if s.FreePageN < 25 {
+		t.Fatalf("unexpected free pages: %d", s.FreePageN)
+	}
+}
+
 func fillBucket(b *bolt.Bucket, prefix []byte) error {
 	n := 10 + rand.Intn(50)
 	for i := 0; i < n; i++ {
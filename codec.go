@@ -0,0 +1,131 @@
+//go:build go1.18
+// +build go1.18
+
+package bolt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Integer is a constraint that permits any integer type.
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// IntCodec is an order-preserving codec for integers. Values are encoded as
+// 8-byte big endian integers. Signed values have their sign bit flipped so
+// that negative values sort before positive values.
+type IntCodec[T Integer] struct{}
+
+// Encode encodes v as an 8-byte key.
+func (IntCodec[T]) Encode(v T) ([]byte, error) {
+	u := uint64(v)
+	if signed[T]() {
+		u ^= 1 << 63
+	}
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, u)
+	return b, nil
+}
+
+// Decode decodes an 8-byte key.
+func (IntCodec[T]) Decode(b []byte) (T, error) {
+	if len(b) != 8 {
+		return 0, fmt.Errorf("invalid integer length: %d", len(b))
+	}
+	u := binary.BigEndian.Uint64(b)
+	if signed[T]() {
+		u ^= 1 << 63
+	}
+	return T(u), nil
+}
+
+// signed returns true if T is a signed integer type.
+func signed[T Integer]() bool {
+	var zero T
+	return ^zero < zero
+}
+
+// StringCodec encodes strings as their raw bytes. It preserves order.
+type StringCodec struct{}
+
+// Encode returns the bytes of v.
+func (StringCodec) Encode(v string) ([]byte, error) { return []byte(v), nil }
+
+// Decode returns b as a string.
+func (StringCodec) Decode(b []byte) (string, error) { return string(b), nil }
+
+// BytesCodec passes byte slices through unchanged. It preserves order.
+// Decoded slices are only valid for the life of the transaction.
+type BytesCodec struct{}
+
+// Encode returns v.
+func (BytesCodec) Encode(v []byte) ([]byte, error) { return v, nil }
+
+// Decode returns b.
+func (BytesCodec) Decode(b []byte) ([]byte, error) { return b, nil }
+
+// TimeCodec is an order-preserving codec for times. Times are encoded as a
+// 12-byte key holding the Unix seconds followed by the nanoseconds. The
+// location and monotonic clock reading are not stored; decoded times are
+// in UTC.
+type TimeCodec struct{}
+
+// Encode encodes v as a 12-byte key.
+func (TimeCodec) Encode(v time.Time) ([]byte, error) {
+	b := make([]byte, 12)
+	binary.BigEndian.PutUint64(b[0:8], uint64(v.Unix())^(1<<63))
+	binary.BigEndian.PutUint32(b[8:12], uint32(v.Nanosecond()))
+	return b, nil
+}
+
+// Decode decodes a 12-byte key.
+func (TimeCodec) Decode(b []byte) (time.Time, error) {
+	if len(b) != 12 {
+		return time.Time{}, fmt.Errorf("invalid time length: %d", len(b))
+	}
+	sec := int64(binary.BigEndian.Uint64(b[0:8]) ^ (1 << 63))
+	nsec := int64(binary.BigEndian.Uint32(b[8:12]))
+	return time.Unix(sec, nsec).UTC(), nil
+}
+
+// JSONCodec encodes values using encoding/json. It does not preserve order
+// and should only be used for values.
+type JSONCodec[T any] struct{}
+
+// Encode marshals v to JSON.
+func (JSONCodec[T]) Encode(v T) ([]byte, error) { return json.Marshal(v) }
+
+// Decode unmarshals b from JSON.
+func (JSONCodec[T]) Decode(b []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(b, &v)
+	return v, err
+}
+
+// GobCodec encodes values using encoding/gob. Each value is encoded as a
+// separate stream so it includes its type information. It does not preserve
+// order and should only be used for values.
+type GobCodec[T any] struct{}
+
+// Encode encodes v with gob.
+func (GobCodec[T]) Encode(v T) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode decodes b with gob.
+func (GobCodec[T]) Decode(b []byte) (T, error) {
+	var v T
+	err := gob.NewDecoder(bytes.NewReader(b)).Decode(&v)
+	return v, err
+}
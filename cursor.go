@@ -18,6 +18,7 @@ import (
 type Cursor struct {
 	bucket *Bucket
 	stack  []elemRef
+	keys   keyBuffer
 }
 
 // Bucket returns the bucket that this cursor was created from.
@@ -145,6 +146,82 @@ func (c *Cursor) Delete() error {
 		return ErrIncompatibleValue
 	}
 	c.node().del(key)
+	c.bucket.tx.writeBytes += len(key)
+
+	return nil
+}
+
+// Put replaces the value of the current key under the cursor.
+// The cursor remains positioned on the key so Next and Prev continue from it.
+// Put fails if the cursor is not positioned on a key, if the current key is a
+// bucket or if the transaction is not writable.
+//
+// Supplied value must remain valid for the life of the transaction.
+func (c *Cursor) Put(value []byte) error {
+	if c.bucket.tx.db == nil {
+		return ErrTxClosed
+	} else if !c.bucket.Writable() {
+		return ErrTxNotWritable
+	} else if int64(len(value)) > MaxValueSize {
+		return ErrValueTooLarge
+	} else if len(c.stack) == 0 {
+		return ErrKeyRequired
+	}
+
+	key, _, flags := c.keyValue()
+	if key == nil {
+		return ErrKeyRequired
+	} else if (flags & bucketLeafFlag) != 0 {
+		return ErrIncompatibleValue
+	}
+
+	// The key already exists so the number of elements doesn't change and
+	// the index on the stack remains valid. The top of the stack needs to
+	// reference the node instead of the page so that the cursor doesn't see
+	// the old value when it moves.
+	key = cloneBytes(key)
+	n := c.node()
+	n.put(key, key, value, 0, 0)
+	c.stack[len(c.stack)-1].node = n
+	c.bucket.tx.writeBytes += len(key) + len(value)
+
+	return nil
+}
+
+// Insert sets the value for a key in the bucket and moves the cursor to it.
+// If the key exists then its value is replaced. Next and Prev continue from
+// the inserted key. Insert fails for the same reasons as Bucket.Put.
+//
+// Supplied value must remain valid for the life of the transaction.
+func (c *Cursor) Insert(key []byte, value []byte) error {
+	if c.bucket.tx.db == nil {
+		return ErrTxClosed
+	} else if !c.bucket.Writable() {
+		return ErrTxNotWritable
+	} else if len(key) == 0 {
+		return ErrKeyRequired
+	} else if len(key) > MaxKeySize {
+		return ErrKeyTooLarge
+	} else if int64(len(value)) > MaxValueSize {
+		return ErrValueTooLarge
+	}
+
+	// Move cursor to the insertion point.
+	k, _, flags := c.seek(key)
+
+	// Return an error if there is an existing key with a bucket value.
+	if bytes.Equal(key, k) && (flags&bucketLeafFlag) != 0 {
+		return ErrIncompatibleValue
+	}
+
+	// Insert into node. The new element takes the index that the cursor
+	// already points to so the top of the stack just needs to reference
+	// the node instead of the page.
+	key = cloneBytes(key)
+	n := c.node()
+	n.put(key, key, value, 0, 0)
+	c.stack[len(c.stack)-1].node = n
+	c.bucket.tx.writeBytes += len(key) + len(value)
 
 	return nil
 }
@@ -328,6 +405,20 @@ func (c *Cursor) nsearch(key []byte) {
 		return
 	}
 
+	// If the page has a shared key prefix then compare the key against it
+	// first and search the remaining suffixes.
+	if prefix := p.keyPrefix(); prefix != nil {
+		if !bytes.HasPrefix(key, prefix) {
+			if bytes.Compare(key, prefix) < 0 {
+				e.index = 0
+			} else {
+				e.index = int(p.count)
+			}
+			return
+		}
+		key = key[len(prefix):]
+	}
+
 	// If we have a page then search its leaf elements.
 	inodes := p.leafPageElements()
 	index := sort.Search(int(p.count), func(i int) bool {
@@ -351,7 +442,7 @@ func (c *Cursor) keyValue() ([]byte, []byte, uint32) {
 
 	// Or retrieve value from page.
 	elem := ref.page.leafPageElement(uint16(ref.index))
-	return elem.key(), elem.value(), elem.flags
+	return ref.page.leafKey(uint16(ref.index), &c.keys), elem.value(), elem.flags
 }
 
 // node returns the node that the cursor is currently positioned on.
@@ -161,6 +161,211 @@ func TestCursor_Delete(t *testing.T) {
 	}
 }
 
+// Ensure that a cursor can replace values in place while iterating.
+func TestCursor_Put(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+
+	const count = 1000
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < count; i++ {
+			if err := b.Put(u64tob(uint64(i)), make([]byte, 100)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := b.CreateBucket([]byte("sub")); err != nil {
+			t.Fatal(err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte("widgets")).Cursor()
+
+		// Replace every value, moving forward then backward.
+		var n int
+		for k, _ := c.First(); k != nil && len(k) == 8; k, _ = c.Next() {
+			if err := c.Put([]byte(fmt.Sprintf("%d", btou64(k)))); err != nil {
+				t.Fatal(err)
+			}
+			n++
+		}
+		if n != count {
+			t.Fatalf("unexpected count: %d", n)
+		}
+		if k, _ := c.Seek(u64tob(500)); !bytes.Equal(k, u64tob(500)) {
+			t.Fatalf("unexpected key: %x", k)
+		} else if err := c.Put([]byte("x")); err != nil {
+			t.Fatal(err)
+		} else if k, _ := c.Prev(); !bytes.Equal(k, u64tob(499)) {
+			t.Fatalf("unexpected key: %x", k)
+		}
+
+		// Values on buckets and unpositioned cursors cannot be replaced.
+		c.Seek([]byte("sub"))
+		if err := c.Put([]byte("x")); err != bolt.ErrIncompatibleValue {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := tx.Bucket([]byte("widgets")).Cursor().Put([]byte("x")); err != bolt.ErrKeyRequired {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("widgets"))
+		for i := 0; i < count; i++ {
+			exp := fmt.Sprintf("%d", i)
+			if i == 500 {
+				exp = "x"
+			}
+			if v := b.Get(u64tob(uint64(i))); string(v) != exp {
+				t.Fatalf("unexpected value(%d): %q", i, v)
+			}
+		}
+
+		if err := b.Cursor().Put([]byte("x")); err != bolt.ErrTxNotWritable {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure that a cursor sees a value replaced by Put after it moves.
+func TestCursor_Put_Move(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, k := range []string{"a", "b", "c"} {
+			if err := b.Put([]byte(k), []byte("old")); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte("widgets")).Cursor()
+		if k, _ := c.Seek([]byte("b")); string(k) != "b" {
+			t.Fatalf("unexpected key: %s", k)
+		} else if err := c.Put([]byte("new")); err != nil {
+			t.Fatal(err)
+		}
+
+		if k, v := c.Prev(); string(k) != "a" || string(v) != "old" {
+			t.Fatalf("unexpected key/value: %s=%s", k, v)
+		} else if k, v := c.Next(); string(k) != "b" || string(v) != "new" {
+			t.Fatalf("unexpected key/value: %s=%s", k, v)
+		} else if k, v := c.Next(); string(k) != "c" || string(v) != "old" {
+			t.Fatalf("unexpected key/value: %s=%s", k, v)
+		} else if k, v := c.Prev(); string(k) != "b" || string(v) != "new" {
+			t.Fatalf("unexpected key/value: %s=%s", k, v)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure that a cursor is positioned on inserted keys and continues from them.
+func TestCursor_Insert(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+
+	const count = 2000
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < count; i += 2 {
+			if err := b.Put(u64tob(uint64(i)), make([]byte, 100)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte("widgets")).Cursor()
+
+		// Insert an odd key after every even key while scanning forward.
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			i := btou64(k)
+			if i%2 != 0 {
+				t.Fatalf("unexpected inserted key: %d", i)
+			}
+			if err := c.Insert(u64tob(i+1), []byte("odd")); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// Insert before the first key and move around it.
+		if err := c.Insert([]byte{0}, []byte("first")); err != nil {
+			t.Fatal(err)
+		} else if k, _ := c.Prev(); k != nil {
+			t.Fatalf("unexpected key: %x", k)
+		}
+		if err := c.Insert(u64tob(1001), []byte("replaced")); err != nil {
+			t.Fatal(err)
+		} else if k, _ := c.Prev(); !bytes.Equal(k, u64tob(1000)) {
+			t.Fatalf("unexpected key: %x", k)
+		} else if k, v := c.Next(); !bytes.Equal(k, u64tob(1001)) || string(v) != "replaced" {
+			t.Fatalf("unexpected key/value: %x/%s", k, v)
+		} else if k, _ := c.Next(); !bytes.Equal(k, u64tob(1002)) {
+			t.Fatalf("unexpected key: %x", k)
+		}
+
+		if err := c.Insert(nil, nil); err != bolt.ErrKeyRequired {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("widgets"))
+		if n := b.Stats().KeyN; n != count+1 {
+			t.Fatalf("unexpected KeyN: %d", n)
+		}
+
+		var prev []byte
+		c := b.Cursor()
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			if prev != nil && bytes.Compare(prev, k) >= 0 {
+				t.Fatalf("out of order: %x >= %x", prev, k)
+			}
+			prev = k
+		}
+		for err := range tx.Check() {
+			t.Fatal(err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
 // Ensure that a Tx cursor can seek to the appropriate keys when there are a
 // large number of keys. This test also checks that seek will always move
 // forward to the next key.
@@ -1,14 +1,14 @@
 package bolt
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"hash/fnv"
-	"log"
+	"io"
+	"math/bits"
 	"os"
 	"runtime"
-	"runtime/debug"
-	"strings"
 	"sync"
 	"time"
 	"unsafe"
@@ -20,9 +20,46 @@ const maxMmapStep = 1 << 30 // 1GB
 // The data file format version.
 const version = 2
 
+// The data file format version of files that use required features.
+const featureVersion = 3
+
 // Represents a marker value to indicate that a file is a Bolt DB.
 const magic uint32 = 0xED0CDAED
 
+// Feature flags stored in the meta page. Required features occupy the low 16
+// bits and change how pages are encoded, so a file can only be opened if every
+// required feature it uses is supported. Optional features occupy the high 16
+// bits and can be ignored by versions of Bolt that do not know them.
+const (
+	// prefixCompressionFlag indicates that leaf pages may be written with
+	// their shared key prefix stored once per page.
+	prefixCompressionFlag = 0x01
+
+	requiredFeatureMask = 0x0000FFFF
+	supportedFeatures   = prefixCompressionFlag
+)
+
+// featureNames maps feature flags to the names used in errors and Info.
+var featureNames = map[uint32]string{
+	prefixCompressionFlag: "prefix-compression",
+}
+
+// features returns the names of the feature flags set in flags. Unknown
+// flags are named by their bit value.
+func features(flags uint32) []string {
+	var a []string
+	for bit := uint32(1); bit != 0; bit <<= 1 {
+		if flags&bit == 0 {
+			continue
+		} else if name, ok := featureNames[bit]; ok {
+			a = append(a, name)
+		} else {
+			a = append(a, fmt.Sprintf("0x%x", bit))
+		}
+	}
+	return a
+}
+
 // IgnoreNoSync specifies whether the NoSync field of a DB is ignored when
 // syncing changes to a file.  This is required as some operating systems,
 // such as OpenBSD, do not have a unified buffer cache (UBC) and writes
@@ -39,6 +76,12 @@ const (
 // default page size for db is set to the OS page size.
 var defaultPageSize = os.Getpagesize()
 
+// The range of page sizes allowed by Options.PageSize.
+const (
+	minPageSize = 1024
+	maxPageSize = 1 << 20
+)
+
 // DB represents a collection of buckets persisted to a file on disk.
 // All data access is performed through transactions which can be obtained through the DB.
 // All the functions on DB will return a ErrDatabaseNotOpen if accessed before Open() is called.
@@ -89,6 +132,21 @@ type DB struct {
 	// Do not change concurrently with calls to Batch.
 	MaxBatchDelay time.Duration
 
+	// MaxBatchBytes is the maximum combined size of the keys and values
+	// written by the functions in a single batch transaction. Once a function
+	// pushes the transaction over the limit it is committed and the remaining
+	// functions continue in a new transaction.
+	//
+	// Puts count the key and value, deletes count the key and new buckets
+	// count the name and the empty bucket. A range deletion only counts its
+	// bounds since pages entirely within the range are freed without being
+	// rewritten. Sequence changes are not counted.
+	//
+	// If <=0, batches are not limited by size.
+	//
+	// Do not change concurrently with calls to Batch.
+	MaxBatchBytes int
+
 	// AllocSize is the amount of space allocated when the database
 	// needs to create new pages. This is done to amortize the cost
 	// of truncate() and fsync() when growing the data file.
@@ -98,6 +156,7 @@ type DB struct {
 	file     *os.File
 	lockfile *os.File // windows only
 	dataref  []byte   // mmap'ed readonly, write throws SEGV
+	memdata  []byte   // backing data when opened with OpenBytes
 	data     *[maxMapSize]byte
 	datasz   int
 	filesz   int // current on disk file size
@@ -110,7 +169,11 @@ type DB struct {
 	freelist *freelist
 	stats    Stats
 
-	pagePool sync.Pool
+	pagePool          sync.Pool
+	logger            Logger
+	tracer            Tracer
+	mmapGrowth        MmapGrowth
+	prefixCompression bool
 
 	batchMu sync.Mutex
 	batch   *batch
@@ -156,6 +219,23 @@ func Open(path string, mode os.FileMode, options *Options) (*DB, error) {
 	}
 	db.NoGrowSync = options.NoGrowSync
 	db.MmapFlags = options.MmapFlags
+	db.logger = options.Logger
+	if db.logger == nil {
+		db.logger = discardLogger{}
+	}
+	db.tracer = options.Tracer
+	if db.tracer == nil {
+		db.tracer = NopTracer{}
+	}
+	if options.MmapGrowth != nil {
+		db.mmapGrowth = *options.MmapGrowth
+	}
+	db.prefixCompression = options.PrefixCompression
+
+	// Page size of new databases. Existing files use their own page size.
+	if db.pageSize = options.PageSize; db.pageSize == 0 {
+		db.pageSize = defaultPageSize
+	}
 
 	// Set default values for later DB operations.
 	db.MaxBatchSize = DefaultMaxBatchSize
@@ -195,27 +275,28 @@ func Open(path string, mode os.FileMode, options *Options) (*DB, error) {
 	if info, err := db.file.Stat(); err != nil {
 		return nil, err
 	} else if info.Size() == 0 {
+		// Only new files use the requested page size so only they check it.
+		if sz := options.PageSize; sz != 0 && (sz < minPageSize || sz > maxPageSize || sz&(sz-1) != 0) {
+			_ = db.close()
+			return nil, ErrInvalidPageSize
+		}
+
 		// Initialize new files with meta pages.
 		if err := db.init(); err != nil {
 			return nil, err
 		}
-	} else {
-		// Read the first meta page to determine the page size.
-		var buf [0x1000]byte
-		if _, err := db.file.ReadAt(buf[:], 0); err == nil {
-			m := db.pageInBuffer(buf[:], 0).meta()
-			if err := m.validate(); err != nil {
-				// If we can't read the page size, we can assume it's the same
-				// as the OS -- since that's how the page size was chosen in the
-				// first place.
-				//
-				// If the first page is invalid and this OS uses a different
-				// page size than what the database was created with then we
-				// are out of luck and cannot access the database.
-				db.pageSize = os.Getpagesize()
-			} else {
-				db.pageSize = int(m.pageSize)
-			}
+	} else if db.pageSize, err = readPageSize(db.file); err == ErrForeignEndian {
+		_ = db.close()
+		return nil, err
+	} else if db.pageSize == 0 {
+		// If neither meta page can be found, we assume the page size is the
+		// same as the OS. Validating the meta pages after the file is mapped
+		// will report why the file cannot be opened, unless it is too small
+		// to hold them at all.
+		db.pageSize = os.Getpagesize()
+		if info.Size() < int64(db.pageSize*2) {
+			_ = db.close()
+			return nil, ErrInvalid
 		}
 	}
 
@@ -240,9 +321,92 @@ func Open(path string, mode os.FileMode, options *Options) (*DB, error) {
 	return db, nil
 }
 
+// OpenBytes creates a read-only database backed by an in-memory byte slice.
+// The database has no path, file lock or mmap. The slice is used directly so
+// it must not be modified until the database is closed.
+//
+// The meta pages are validated the same way as Open and the slice must hold
+// every page up to the high water mark. Writable transactions return
+// ErrDatabaseReadOnly.
+func OpenBytes(data []byte) (*DB, error) {
+	var db = &DB{opened: true, readOnly: true}
+	db.logger = discardLogger{}
+	db.tracer = NopTracer{}
+	db.MaxBatchSize = DefaultMaxBatchSize
+	db.MaxBatchDelay = DefaultMaxBatchDelay
+	db.AllocSize = DefaultAllocSize
+
+	if len(data) < pageHeaderSize+int(unsafe.Sizeof(meta{})) {
+		return nil, fmt.Errorf("file size too small")
+	}
+
+	// Read the meta pages to determine the page size. Fall back to the OS
+	// page size if neither is valid, as Open does.
+	var err error
+	if db.pageSize, err = readPageSize(bytes.NewReader(data)); err == ErrForeignEndian {
+		return nil, err
+	} else if db.pageSize == 0 {
+		db.pageSize = os.Getpagesize()
+	}
+	if len(data) < db.pageSize*2 {
+		return nil, fmt.Errorf("file size too small")
+	}
+
+	db.memdata = data
+	db.data = (*[maxMapSize]byte)(unsafe.Pointer(&data[0]))
+	db.datasz = len(data)
+	db.filesz = len(data)
+	db.stats.MmapSize = len(data)
+
+	// Validate the meta pages. Only return an error if both fail.
+	db.meta0 = db.page(0).meta()
+	db.meta1 = db.page(1).meta()
+	err0 := db.meta0.validate()
+	err1 := db.meta1.validate()
+	if err0 != nil && err1 != nil {
+		return nil, err0
+	}
+
+	// Unlike a file, which is grown before its meta page is written, the
+	// slice may have been truncated. Ensure every page up to the high water
+	// mark is present and that the freelist and root lie below it.
+	m := db.meta()
+	if int64(m.pgid)*int64(db.pageSize) > int64(len(data)) {
+		return nil, fmt.Errorf("file size too small: %d bytes, expected %d pages", len(data), m.pgid)
+	} else if m.freelist >= m.pgid || m.root.root >= m.pgid {
+		return nil, ErrInvalid
+	} else if m.freelist+pgid(db.page(m.freelist).overflow) >= m.pgid {
+		return nil, ErrInvalid
+	}
+
+	// Read in the freelist.
+	db.freelist = newFreelist()
+	db.freelist.read(db.page(m.freelist))
+
+	return db, nil
+}
+
+// OpenReaderAt creates a read-only database from the first size bytes of r.
+// The contents are read into memory once and r is not used afterwards.
+// See OpenBytes for details.
+func OpenReaderAt(r io.ReaderAt, size int64) (*DB, error) {
+	if size > maxMapSize {
+		return nil, fmt.Errorf("mmap too large")
+	} else if size < 0 {
+		return nil, fmt.Errorf("invalid size: %d", size)
+	}
+
+	data := make([]byte, size)
+	if n, err := r.ReadAt(data, 0); err != nil && !(err == io.EOF && int64(n) == size) {
+		return nil, err
+	}
+	return OpenBytes(data)
+}
+
 // mmap opens the underlying memory-mapped file and initializes the meta references.
 // minsz is the minimum size that the new mmap can be.
 func (db *DB) mmap(minsz int) error {
+	var startTime = time.Now()
 	db.mmaplock.Lock()
 	defer db.mmaplock.Unlock()
 
@@ -269,6 +433,7 @@ func (db *DB) mmap(minsz int) error {
 	}
 
 	// Unmap existing data before continuing.
+	var oldsz = db.datasz
 	if err := db.munmap(); err != nil {
 		return err
 	}
@@ -291,21 +456,42 @@ func (db *DB) mmap(minsz int) error {
 		return err0
 	}
 
+	var elapsed = time.Since(startTime)
+	db.tracer.Remap(oldsz, size, elapsed)
+
+	// Update the mmap stats. The initial mapping is not counted as a remap.
+	db.statlock.Lock()
+	db.stats.MmapSize = size
+	if oldsz > 0 {
+		db.stats.RemapN++
+		db.stats.RemapTime += elapsed
+	}
+	db.statlock.Unlock()
+
 	return nil
 }
 
 // munmap unmaps the data file from memory.
 func (db *DB) munmap() error {
 	if err := munmap(db); err != nil {
-		return fmt.Errorf("unmap error: " + err.Error())
+		return fmt.Errorf("unmap error: %s", err)
 	}
 	return nil
 }
 
 // mmapSize determines the appropriate size for the mmap given the current size
 // of the database. The minimum size is 32KB and doubles until it reaches 1GB.
+// If a growth policy is set then it is used instead.
 // Returns an error if the new mmap size is greater than the max allowed.
 func (db *DB) mmapSize(size int) (int, error) {
+	if db.mmapGrowth.enabled() {
+		return db.mmapGrowth.size(size, db.pageSize)
+	}
+	return defaultMmapSize(size, db.pageSize)
+}
+
+// defaultMmapSize returns the mmap size used when no growth policy is set.
+func defaultMmapSize(size int, pageSize int) (int, error) {
 	// Double the size from 32KB until 1GB.
 	for i := uint(15); i <= 30; i++ {
 		if size <= 1<<i {
@@ -326,9 +512,8 @@ func (db *DB) mmapSize(size int) (int, error) {
 
 	// Ensure that the mmap size is a multiple of the page size.
 	// This should always be true since we're incrementing in MBs.
-	pageSize := int64(db.pageSize)
-	if (sz % pageSize) != 0 {
-		sz = ((sz / pageSize) + 1) * pageSize
+	if (sz % int64(pageSize)) != 0 {
+		sz = ((sz / int64(pageSize)) + 1) * int64(pageSize)
 	}
 
 	// If we've exceeded the max size then only grow up to the max size.
@@ -341,9 +526,6 @@ func (db *DB) mmapSize(size int) (int, error) {
 
 // init creates a new database file and initializes its meta pages.
 func (db *DB) init() error {
-	// Set the page size to the OS page size.
-	db.pageSize = os.Getpagesize()
-
 	// Create two meta pages on a buffer.
 	buf := make([]byte, db.pageSize*4)
 	for i := 0; i < 2; i++ {
@@ -418,13 +600,20 @@ func (db *DB) close() error {
 		return err
 	}
 
+	// Release in-memory data.
+	if db.memdata != nil {
+		db.memdata = nil
+		db.data = nil
+		db.datasz = 0
+	}
+
 	// Close file handles.
 	if db.file != nil {
 		// No need to unlock read-only file.
 		if !db.readOnly {
 			// Unlock the file.
 			if err := funlock(db); err != nil {
-				log.Printf("bolt.Close(): funlock error: %s", err)
+				db.logger.Errorf("bolt.Close(): funlock error: %s", err)
 			}
 		}
 
@@ -467,6 +656,7 @@ func (db *DB) beginTx() (*Tx, error) {
 	// Lock the meta pages while we initialize the transaction. We obtain
 	// the meta lock before the mmap lock because that's the order that the
 	// write transaction will obtain them.
+	var startTime = time.Now()
 	db.metalock.Lock()
 
 	// Obtain a read-only lock on the mmap. When the mmap is remapped it will
@@ -484,6 +674,8 @@ func (db *DB) beginTx() (*Tx, error) {
 	// Create a transaction associated with the database.
 	t := &Tx{}
 	t.init(db)
+	db.tracer.TxBegin(t)
+	db.tracer.TxLockAcquired(t, time.Since(startTime))
 
 	// Keep track of transaction until it closes.
 	db.txs = append(db.txs, t)
@@ -509,6 +701,7 @@ func (db *DB) beginRWTx() (*Tx, error) {
 
 	// Obtain writer lock. This is released by the transaction when it closes.
 	// This enforces only one writer transaction at a time.
+	var startTime = time.Now()
 	db.rwlock.Lock()
 
 	// Once we have the writer lock then we can lock the meta pages so that
@@ -525,7 +718,19 @@ func (db *DB) beginRWTx() (*Tx, error) {
 	// Create a transaction associated with the database.
 	t := &Tx{writable: true}
 	t.init(db)
+
+	// Clear optional features this version does not maintain and mark files
+	// using required features with the newer format version.
+	t.meta.flags &^= ^uint32(requiredFeatureMask) &^ supportedFeatures
+	if db.prefixCompression {
+		t.meta.flags |= prefixCompressionFlag
+	}
+	if t.meta.flags&requiredFeatureMask != 0 {
+		t.meta.version = featureVersion
+	}
 	db.rwtx = t
+	db.tracer.TxBegin(t)
+	db.tracer.TxLockAcquired(t, time.Since(startTime))
 
 	// Free any pages associated with closed read-only transactions.
 	var minid txid = 0xFFFFFFFFFFFFFFFF
@@ -654,11 +859,22 @@ func (db *DB) View(fn func(*Tx) error) error {
 // caller.
 //
 // The maximum batch size and delay can be adjusted with DB.MaxBatchSize
-// and DB.MaxBatchDelay, respectively.
+// and DB.MaxBatchDelay, respectively. The size of each batch transaction
+// can be limited with DB.MaxBatchBytes.
 //
 // Batch is only useful when there are multiple goroutines calling it.
 func (db *DB) Batch(fn func(*Tx) error) error {
-	errCh := make(chan error, 1)
+	_, err := db.BatchValue(func(tx *Tx) (interface{}, error) {
+		return nil, fn(tx)
+	})
+	return err
+}
+
+// BatchValue calls fn as part of a batch and returns the value returned by
+// fn once the batch has been committed. If fn is called multiple times then
+// the value from the last call is returned. See Batch for details.
+func (db *DB) BatchValue(fn func(*Tx) (interface{}, error)) (interface{}, error) {
+	ch := make(chan callResult, 1)
 
 	db.batchMu.Lock()
 	if (db.batch == nil) || (db.batch != nil && len(db.batch.calls) >= db.MaxBatchSize) {
@@ -668,23 +884,38 @@ func (db *DB) Batch(fn func(*Tx) error) error {
 		}
 		db.batch.timer = time.AfterFunc(db.MaxBatchDelay, db.batch.trigger)
 	}
-	db.batch.calls = append(db.batch.calls, call{fn: fn, err: errCh})
+	db.batch.calls = append(db.batch.calls, call{fn: fn, result: ch, start: time.Now()})
 	if len(db.batch.calls) >= db.MaxBatchSize {
 		// wake up batch, it's ready to run
 		go db.batch.trigger()
 	}
 	db.batchMu.Unlock()
 
-	err := <-errCh
-	if err == trySolo {
-		err = db.Update(fn)
+	r := <-ch
+	if r.err == trySolo {
+		db.statlock.Lock()
+		db.stats.BatchSoloN++
+		db.statlock.Unlock()
+
+		r.err = db.Update(func(tx *Tx) error {
+			var err error
+			r.value, err = fn(tx)
+			return err
+		})
 	}
-	return err
+	return r.value, r.err
 }
 
 type call struct {
-	fn  func(*Tx) error
-	err chan<- error
+	fn     func(*Tx) (interface{}, error)
+	result chan<- callResult
+	start  time.Time
+	value  interface{}
+}
+
+type callResult struct {
+	value interface{}
+	err   error
 }
 
 type batch struct {
@@ -714,12 +945,22 @@ func (b *batch) run() {
 retry:
 	for len(b.calls) > 0 {
 		var failIdx = -1
+		var n = len(b.calls)
 		err := b.db.Update(func(tx *Tx) error {
-			for i, c := range b.calls {
-				if err := safelyCall(c.fn, tx); err != nil {
+			for i := range b.calls {
+				c := &b.calls[i]
+				v, err := safelyCall(c.fn, tx)
+				if err != nil {
 					failIdx = i
 					return err
 				}
+				c.value = v
+
+				// commit early if the transaction has grown past the size limit.
+				if max := b.db.MaxBatchBytes; max > 0 && tx.writeBytes >= max {
+					n = i + 1
+					break
+				}
 			}
 			return nil
 		})
@@ -731,15 +972,38 @@ retry:
 			c := b.calls[failIdx]
 			b.calls[failIdx], b.calls = b.calls[len(b.calls)-1], b.calls[:len(b.calls)-1]
 			// tell the submitter re-run it solo, continue with the rest of the batch
-			c.err <- trySolo
+			c.result <- callResult{err: trySolo}
 			continue retry
 		}
 
-		// pass success, or bolt internal errors, to all callers
-		for _, c := range b.calls {
-			c.err <- err
+		// Only count committed transactions. Each call waited from the
+		// moment it was queued until the commit that included it.
+		if err == nil {
+			var wait time.Duration
+			for _, c := range b.calls[:n] {
+				wait += time.Since(c.start)
+			}
+
+			b.db.statlock.Lock()
+			b.db.stats.BatchN++
+			b.db.stats.BatchCallN += n
+			if n < len(b.calls) {
+				b.db.stats.BatchSplitN++
+			}
+			b.db.stats.BatchWait += wait
+			b.db.statlock.Unlock()
+		}
+
+		// pass success, or bolt internal errors, to the callers in this
+		// transaction and continue with any calls left by a size limit.
+		for _, c := range b.calls[:n] {
+			if err != nil {
+				c.result <- callResult{err: err}
+			} else {
+				c.result <- callResult{value: c.value}
+			}
 		}
-		break retry
+		b.calls = b.calls[n:]
 	}
 }
 
@@ -759,9 +1023,10 @@ func (p panicked) Error() string {
 	return fmt.Sprintf("panic: %v", p.reason)
 }
 
-func safelyCall(fn func(*Tx) error, tx *Tx) (err error) {
+func safelyCall(fn func(*Tx) (interface{}, error), tx *Tx) (v interface{}, err error) {
 	defer func() {
 		if p := recover(); p != nil {
+			tx.db.logger.Errorf("bolt: batch function panicked: %v", p)
 			err = panicked{p}
 		}
 	}()
@@ -769,10 +1034,16 @@ func safelyCall(fn func(*Tx) error, tx *Tx) (err error) {
 }
 
 // Sync executes fdatasync() against the database file handle.
+// It is a no-op for databases opened with OpenBytes or OpenReaderAt.
 //
 // This is not necessary under normal operation, however, if you use NoSync
 // then it allows you to force the database file to sync against the disk.
-func (db *DB) Sync() error { return fdatasync(db) }
+func (db *DB) Sync() error {
+	if db.file == nil {
+		return nil
+	}
+	return fdatasync(db)
+}
 
 // Stats retrieves ongoing performance stats for the database.
 // This is only updated when a transaction closes.
@@ -782,10 +1053,26 @@ func (db *DB) Stats() Stats {
 	return db.stats
 }
 
+// FreelistStats returns statistics about the free pages of the database which
+// help to decide whether compacting it is worthwhile. It waits for an open
+// read-write transaction to close so it must not be called from within one.
+func (db *DB) FreelistStats() (FreelistStats, error) {
+	db.rwlock.Lock()
+	defer db.rwlock.Unlock()
+	db.metalock.Lock()
+	defer db.metalock.Unlock()
+
+	if !db.opened {
+		return FreelistStats{}, ErrDatabaseNotOpen
+	}
+	return db.freelist.stats(db.meta().pgid), nil
+}
+
 // This is for internal access to the raw data bytes from the C cursor, use
 // carefully, or not at all.
 func (db *DB) Info() *Info {
-	return &Info{uintptr(unsafe.Pointer(&db.data[0])), db.pageSize}
+	m := db.meta()
+	return &Info{uintptr(unsafe.Pointer(&db.data[0])), db.pageSize, int(m.version), features(m.flags)}
 }
 
 // page retrieves a page reference from the mmap based on the current page size.
@@ -794,6 +1081,44 @@ func (db *DB) page(id pgid) *page {
 	return (*page)(unsafe.Pointer(&db.data[pos]))
 }
 
+// readPageSize returns the page size of an existing data file. The first meta
+// page is always at the start of the file. If it is invalid then the second
+// meta page is looked for at the offset of each allowed page size, starting
+// with the OS page size, so files created with a different page size can
+// still be opened. Returns zero and the error from the first meta page if
+// neither meta page is valid.
+func readPageSize(r io.ReaderAt) (int, error) {
+	buf := make([]byte, pageHeaderSize+int(unsafe.Sizeof(meta{})))
+	valid := func(off int64) (*meta, error) {
+		if _, err := r.ReadAt(buf, off); err != nil {
+			return nil, ErrInvalid
+		}
+		m := (*page)(unsafe.Pointer(&buf[0])).meta()
+		if err := m.validate(); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+
+	m, err := valid(0)
+	if m != nil {
+		return int(m.pageSize), nil
+	}
+
+	sizes := []int{os.Getpagesize()}
+	for sz := minPageSize; sz <= maxPageSize; sz *= 2 {
+		if sz != sizes[0] {
+			sizes = append(sizes, sz)
+		}
+	}
+	for _, sz := range sizes {
+		if m, _ := valid(int64(sz)); m != nil && int(m.pageSize) == sz {
+			return sz, nil
+		}
+	}
+	return 0, err
+}
+
 // pageInBuffer retrieves a page reference from a given byte array based on the current page size.
 func (db *DB) pageInBuffer(b []byte, id pgid) *page {
 	return (*page)(unsafe.Pointer(&b[id*pgid(db.pageSize)]))
@@ -917,6 +1242,129 @@ type Options struct {
 	// If initialMmapSize is smaller than the previous database size,
 	// it takes no effect.
 	InitialMmapSize int
+
+	// Logger receives diagnostic messages such as unmap failures, lock
+	// retries, strict mode check failures and batch panics.
+	//
+	// If nil, messages are discarded.
+	Logger Logger
+
+	// Tracer receives callbacks for each phase of a transaction's lifecycle
+	// and for every remap of the data file.
+	//
+	// If nil, no tracing is performed.
+	Tracer Tracer
+
+	// MmapGrowth sets the policy used to grow the memory map as the data
+	// file grows. Every remap blocks new read transactions until open read
+	// transactions finish, so reserving more virtual address space up front
+	// results in fewer stalls on write-heavy databases.
+	//
+	// If nil, the map doubles from 32KB until 1GB and then grows by 1GB
+	// at a time.
+	MmapGrowth *MmapGrowth
+
+	// PageSize sets the page size of a new database. It must be a power of
+	// two between 1KB and 1MB. Larger pages suit large values and long
+	// scans. Existing files always use the page size they were created with.
+	//
+	// If zero, the OS page size is used.
+	PageSize int
+
+	// PrefixCompression enables prefix compression of leaf pages. Keys on a
+	// leaf page are stored without the prefix they all share, which makes
+	// files much smaller when keys have long common prefixes.
+	//
+	// Compression applies to every bucket in the database. The setting is
+	// saved in the file by the next write transaction and stays enabled
+	// afterwards, even if the file is later opened without this option.
+	// This upgrades the file to format version 3, which older versions of
+	// Bolt cannot open. Use "bolt downgrade" to convert it back.
+	PrefixCompression bool
+}
+
+// MmapGrowth represents a policy for growing the memory map.
+//
+// When the map needs to cover a larger file, the required size is first
+// increased by Percent and then rounded up to a multiple of Step. The result
+// is limited to MaxSize. Once the required size is past MaxSize the map keeps
+// growing by Step, or by Percent if Step is not set, so that it is not
+// remapped on every allocation. If neither Step nor Percent is set then the
+// map grows as it does without a policy and MaxSize only limits it. Zero or
+// negative values disable the corresponding rule.
+type MmapGrowth struct {
+	// Step is a fixed increment, in bytes, that the map size is rounded up to.
+	Step int
+
+	// Percent is the percentage of the required size reserved beyond it.
+	// For example, 50 maps 1.5GB when 1GB is required.
+	Percent float64
+
+	// MaxSize is the maximum virtual size, in bytes, reserved by the policy
+	// while the required size is below it.
+	MaxSize int
+}
+
+// enabled returns true if any growth rule is set.
+func (g *MmapGrowth) enabled() bool {
+	return g.Step > 0 || g.Percent > 0 || g.MaxSize > 0
+}
+
+// size returns the mmap size to use for a database requiring at least size bytes.
+// Returns an error if size is greater than the max allowed.
+func (g *MmapGrowth) size(size int, pageSize int) (int, error) {
+	// Verify the requested size is not above the maximum allowed.
+	if size > maxMapSize {
+		return 0, fmt.Errorf("mmap too large")
+	}
+
+	// Past the max size only round up to the next step, if there is one,
+	// instead of reserving a percentage of an already large map.
+	var over = g.MaxSize > 0 && size > g.MaxSize
+
+	sz := int64(size)
+	if g.Step <= 0 && g.Percent <= 0 {
+		// Without a rule of its own, grow the same way as the default policy.
+		n, err := defaultMmapSize(size, pageSize)
+		if err != nil {
+			return 0, err
+		}
+		sz = int64(n)
+	}
+
+	// Reserve a percentage beyond the required size.
+	if g.Percent > 0 && !(over && g.Step > 0) {
+		sz += int64(float64(sz) * g.Percent / 100)
+	}
+
+	// Round up to the next step.
+	if g.Step > 0 {
+		if remainder := sz % int64(g.Step); remainder > 0 {
+			sz += int64(g.Step) - remainder
+		}
+	}
+
+	// Limit the reservation while the required size is below the max size.
+	if g.MaxSize > 0 && !over && sz > int64(g.MaxSize) {
+		sz = int64(g.MaxSize)
+	}
+
+	// Keep the same 32KB minimum as the default policy.
+	if sz < 1<<15 {
+		sz = 1 << 15
+	}
+
+	// Ensure that the mmap size is a multiple of the page size.
+	if (sz % int64(pageSize)) != 0 {
+		sz = ((sz / int64(pageSize)) + 1) * int64(pageSize)
+	}
+
+	// If we've exceeded the max size then only grow up to the max size.
+	if sz > maxMapSize {
+		sz = maxMapSize
+	}
+
+	return int(sz), nil
 }
 
 // DefaultOptions represent the options used if nil options are passed into Open().
@@ -934,13 +1382,54 @@ type Stats struct {
 	FreeAlloc     int // total bytes allocated in free pages
 	FreelistInuse int // total bytes used by the freelist
 
+	// Mmap stats
+	MmapSize  int           // current size of the memory map in bytes
+	RemapN    int           // total number of remaps after the initial mapping
+	RemapTime time.Duration // total time spent remapping, including lock waits
+
 	// Transaction stats
 	TxN     int // total number of started read transactions
 	OpenTxN int // number of currently open read transactions
 
+	// Batch stats
+	BatchN      int           // total number of batch transactions committed
+	BatchCallN  int           // total number of calls committed in batch transactions
+	BatchSoloN  int           // total number of calls retried in their own transaction
+	BatchSplitN int           // total number of batches split by MaxBatchBytes
+	BatchWait   time.Duration // total time calls waited for their batch to commit
+
 	TxStats TxStats // global, ongoing stats.
 }
 
+// FreelistStats represents statistics about the free pages of a database.
+// An extent is a run of contiguous free pages. Only pages available for
+// allocation form extents; pending pages are still visible to open read-only
+// transactions and are released once those transactions close.
+type FreelistStats struct {
+	PageN        int // number of pages up to the high water mark
+	FreePageN    int // number of free pages available for allocation
+	PendingPageN int // number of freed pages not yet available for allocation
+
+	ExtentN        int   // number of free extents
+	LargestExtentN int   // number of pages in the largest free extent
+	ExtentSizes    []int // number of extents of 2^i to 2^(i+1)-1 pages at index i
+
+	// Number of free pages at the end of the data which would be reclaimed
+	// if the file was truncated to the last page in use.
+	TailFreePageN int
+
+	// Pending pages grouped by the transaction that freed them, in
+	// transaction order.
+	Pending []PendingPages
+}
+
+// PendingPages represents the pages freed by a transaction which are not yet
+// available for allocation.
+type PendingPages struct {
+	TxID  int // id of the transaction that freed the pages
+	PageN int // number of pages, including overflow pages
+}
+
 // Sub calculates and returns the difference between two sets of database stats.
 // This is useful when obtaining stats at two different points and time and
 // you need the performance counters that occurred within that time span.
@@ -953,7 +1442,15 @@ func (s *Stats) Sub(other *Stats) Stats {
 	diff.PendingPageN = s.PendingPageN
 	diff.FreeAlloc = s.FreeAlloc
 	diff.FreelistInuse = s.FreelistInuse
+	diff.MmapSize = s.MmapSize
+	diff.RemapN = s.RemapN - other.RemapN
+	diff.RemapTime = s.RemapTime - other.RemapTime
 	diff.TxN = s.TxN - other.TxN
+	diff.BatchN = s.BatchN - other.BatchN
+	diff.BatchCallN = s.BatchCallN - other.BatchCallN
+	diff.BatchSoloN = s.BatchSoloN - other.BatchSoloN
+	diff.BatchSplitN = s.BatchSplitN - other.BatchSplitN
+	diff.BatchWait = s.BatchWait - other.BatchWait
 	diff.TxStats = s.TxStats.Sub(&other.TxStats)
 	return diff
 }
@@ -965,6 +1462,11 @@ func (s *Stats) add(other *Stats) {
 type Info struct {
 	Data     uintptr
 	PageSize int
+
+	// Version is the format version of the data file and Features lists
+	// the names of the features it uses.
+	Version  int
+	Features []string
 }
 
 type meta struct {
@@ -981,12 +1483,16 @@ type meta struct {
 
 // validate checks the marker bytes and version of the meta page to ensure it matches this binary.
 func (m *meta) validate() error {
-	if m.magic != magic {
+	if m.magic == bits.ReverseBytes32(magic) {
+		return ErrForeignEndian
+	} else if m.magic != magic {
 		return ErrInvalid
-	} else if m.version != version {
+	} else if m.version != version && m.version != featureVersion {
 		return ErrVersionMismatch
 	} else if m.checksum != 0 && m.checksum != m.sum64() {
 		return ErrChecksum
+	} else if flags := m.flags & requiredFeatureMask &^ supportedFeatures; flags != 0 {
+		return &FeatureError{Flags: flags}
 	}
 	return nil
 }
@@ -1027,11 +1533,3 @@ func _assert(condition bool, msg string, v ...interface{}) {
 		panic(fmt.Sprintf("assertion failed: "+msg, v...))
 	}
 }
-
-func warn(v ...interface{})              { fmt.Fprintln(os.Stderr, v...) }
-func warnf(msg string, v ...interface{}) { fmt.Fprintf(os.Stderr, msg+"\n", v...) }
-
-func printstack() {
-	stack := strings.Join(strings.Split(string(debug.Stack()), "\n")[2:], "\n")
-	fmt.Fprintln(os.Stderr, stack)
-}
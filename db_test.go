@@ -7,11 +7,13 @@ import (
 	"flag"
 	"fmt"
 	"hash/fnv"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"sort"
 	"strings"
 	"sync"
@@ -41,7 +43,7 @@ type meta struct {
 	magic    uint32
 	version  uint32
 	_        uint32
-	_        uint32
+	flags    uint32
 	_        [16]byte
 	_        uint64
 	pgid     uint64
@@ -129,9 +131,9 @@ func TestOpen_ErrVersionMismatch(t *testing.T) {
 
 	// Rewrite meta pages.
 	meta0 := (*meta)(unsafe.Pointer(&buf[pageHeaderSize]))
-	meta0.version++
+	meta0.version = 100
 	meta1 := (*meta)(unsafe.Pointer(&buf[pageSize+pageHeaderSize]))
-	meta1.version++
+	meta1.version = 100
 	if err := ioutil.WriteFile(path, buf, 0666); err != nil {
 		t.Fatal(err)
 	}
@@ -179,6 +181,94 @@ func TestOpen_ErrChecksum(t *testing.T) {
 	}
 }
 
+// Ensure that opening a file that requires an unknown feature returns a
+// FeatureError while unknown optional features are ignored.
+func TestOpen_ErrUnsupportedFeature(t *testing.T) {
+	if pageSize != os.Getpagesize() {
+		t.Skip("page size mismatch")
+	}
+
+	for _, tt := range []struct {
+		flags uint32
+		err   string
+	}{
+		{0x8000, "version mismatch: unsupported features: 0x8000"},
+		{0x10000, ""},
+	} {
+		db := MustOpenDB()
+		path := db.Path()
+		if err := db.DB.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		// Rewrite both meta pages with the flag and a valid checksum.
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, off := range []int{pageHeaderSize, pageSize + pageHeaderSize} {
+			m := (*meta)(unsafe.Pointer(&buf[off]))
+			m.version, m.flags = 3, tt.flags
+			h := fnv.New64a()
+			_, _ = h.Write(buf[off : off+int(unsafe.Offsetof(m.checksum))])
+			m.checksum = h.Sum64()
+		}
+		if err := ioutil.WriteFile(path, buf, 0666); err != nil {
+			t.Fatal(err)
+		}
+
+		// Reopen data file.
+		db2, err := bolt.Open(path, 0666, nil)
+		if tt.err == "" {
+			if err != nil {
+				t.Fatal(err)
+			} else if info := db2.Info(); info.Version != 3 || len(info.Features) != 1 || info.Features[0] != "0x10000" {
+				t.Fatalf("unexpected info: %d, %v", info.Version, info.Features)
+			}
+			db2.Close()
+		} else if ferr, ok := err.(*bolt.FeatureError); !ok || ferr.Flags != tt.flags {
+			t.Fatalf("unexpected error: %v", err)
+		} else if err.Error() != tt.err || !errors.Is(err, bolt.ErrVersionMismatch) {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		os.Remove(path)
+	}
+}
+
+// Ensure that opening a file written with a different byte order returns
+// ErrForeignEndian.
+func TestOpen_ErrForeignEndian(t *testing.T) {
+	if pageSize != os.Getpagesize() {
+		t.Skip("page size mismatch")
+	}
+
+	db := MustOpenDB()
+	path := db.Path()
+	defer db.MustClose()
+	if err := db.DB.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reverse the magic of both meta pages.
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, off := range []int{pageHeaderSize, pageSize + pageHeaderSize} {
+		b := buf[off : off+4]
+		b[0], b[1], b[2], b[3] = b[3], b[2], b[1], b[0]
+	}
+	if err := ioutil.WriteFile(path, buf, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := bolt.Open(path, 0666, nil); err != bolt.ErrForeignEndian {
+		t.Fatalf("unexpected error: %v", err)
+	} else if _, err := bolt.OpenBytes(buf); err != bolt.ErrForeignEndian {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
 // Ensure that opening a database does not increase its size.
 // https://github.com/boltdb/bolt/issues/291
 func TestOpen_Size(t *testing.T) {
@@ -362,6 +452,280 @@ func TestOpen_FileTooSmall(t *testing.T) {
 	}
 }
 
+// Ensure that a new database uses the requested page size and that the page
+// size is read from the file when it is reopened, even if the first meta page
+// is invalid.
+func TestOpen_PageSize(t *testing.T) {
+	path := tempfile()
+	defer os.Remove(path)
+
+	db, err := bolt.Open(path, 0666, &bolt.Options{PageSize: 16384})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 1000; i++ {
+			if err := b.Put(u64tob(uint64(i)), make([]byte, 100)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Commit again so the latest meta is in the second meta page.
+	if err := db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("widgets")).Put([]byte("foo"), []byte("bar"))
+	}); err != nil {
+		t.Fatal(err)
+	} else if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupt the first meta page.
+	f, err := os.OpenFile(path, os.O_WRONLY, 0666)
+	if err != nil {
+		t.Fatal(err)
+	} else if _, err := f.WriteAt(make([]byte, 64), int64(pageHeaderSize)); err != nil {
+		t.Fatal(err)
+	} else if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = bolt.Open(path, 0666, &bolt.Options{PageSize: 4096})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if sz := db.Info().PageSize; sz != 16384 {
+		t.Fatalf("unexpected page size: %d", sz)
+	}
+	if err := db.View(func(tx *bolt.Tx) error {
+		if n := tx.Bucket([]byte("widgets")).Stats().KeyN; n != 1001 {
+			t.Fatalf("unexpected key count: %d", n)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure that an invalid page size returns an error.
+func TestOpen_ErrInvalidPageSize(t *testing.T) {
+	for _, sz := range []int{512, 3000, 2 << 20} {
+		path := tempfile()
+		if _, err := bolt.Open(path, 0666, &bolt.Options{PageSize: sz}); err != bolt.ErrInvalidPageSize {
+			t.Fatalf("%d: unexpected error: %v", sz, err)
+		}
+		os.Remove(path)
+	}
+}
+
+// Ensure that the page size option is ignored for existing files.
+func TestOpen_PageSize_Existing(t *testing.T) {
+	db := MustOpenDB()
+	path := db.Path()
+	defer os.Remove(path)
+	if err := db.DB.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db0, err := bolt.Open(path, 0666, &bolt.Options{PageSize: 3000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db0.Close()
+
+	if sz := db0.Info().PageSize; sz != os.Getpagesize() {
+		t.Fatalf("unexpected page size: %d", sz)
+	}
+}
+
+// Ensure that lock retries and timeouts are reported to the logger.
+func TestOpen_Timeout_Logger(t *testing.T) {
+	if runtime.GOOS == "solaris" {
+		t.Skip("solaris fcntl locks are per-process")
+	}
+
+	db := MustOpenDB()
+	defer db.MustClose()
+
+	var logger testLogger
+	if _, err := bolt.Open(db.Path(), 0666, &bolt.Options{Timeout: 100 * time.Millisecond, Logger: &logger}); err != bolt.ErrTimeout {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if msgs := logger.Messages("DEBUG"); len(msgs) == 0 {
+		t.Fatal("expected lock retry messages")
+	}
+	if msgs := logger.Messages("WARNING"); len(msgs) != 1 {
+		t.Fatalf("unexpected warning messages: %q", msgs)
+	}
+}
+
+// Ensure that a database can be opened from a byte slice and read.
+func TestOpenBytes(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 1000; i++ {
+			if err := b.Put(u64tob(uint64(i)), make([]byte, 100)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(db.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mdb, err := bolt.OpenBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	} else if mdb.Path() != "" {
+		t.Fatalf("unexpected path: %q", mdb.Path())
+	} else if !mdb.IsReadOnly() {
+		t.Fatal("expected read-only database")
+	}
+	defer mdb.Close()
+
+	// Iterate over the bucket with a cursor and copy the database.
+	var buf bytes.Buffer
+	if err := mdb.View(func(tx *bolt.Tx) error {
+		var n uint64
+		c := tx.Bucket([]byte("widgets")).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if btou64(k) != n || len(v) != 100 {
+				t.Fatalf("unexpected key/value: %x/%d", k, len(v))
+			}
+			n++
+		}
+		if n != 1000 {
+			t.Fatalf("unexpected count: %d", n)
+		}
+		for err := range tx.Check() {
+			t.Fatal(err)
+		}
+
+		_, err := tx.WriteTo(&buf)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Writable transactions are not allowed.
+	if err := mdb.Update(func(tx *bolt.Tx) error { return nil }); err != bolt.ErrDatabaseReadOnly {
+		t.Fatalf("unexpected error: %v", err)
+	} else if err := mdb.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Ensure the copy can be reopened from a reader.
+	cdb, err := bolt.OpenReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cdb.Close()
+	if err := cdb.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket([]byte("widgets")).Get(u64tob(999)); len(v) != 100 {
+			t.Fatalf("unexpected value: %x", v)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mdb.Close(); err != nil {
+		t.Fatal(err)
+	} else if _, err := mdb.Begin(false); err != bolt.ErrDatabaseNotOpen {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure that opening invalid data returns an error.
+func TestOpenBytes_ErrInvalid(t *testing.T) {
+	if _, err := bolt.OpenBytes(make([]byte, 4*os.Getpagesize())); err != bolt.ErrInvalid {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := bolt.OpenBytes([]byte("bolt")); err == nil || err.Error() != "file size too small" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure that data truncated below the high water mark returns an error.
+func TestOpenBytes_Truncated(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+	var size int64
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 1000; i++ {
+			if err := b.Put(u64tob(uint64(i)), make([]byte, 100)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	} else if err := db.View(func(tx *bolt.Tx) error {
+		size = tx.Size()
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(db.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int64{int64(len(data) / 8), size - int64(os.Getpagesize())} {
+		if _, err := bolt.OpenBytes(data[:n]); err == nil || !strings.HasPrefix(err.Error(), "file size too small") {
+			t.Fatalf("%d: unexpected error: %v", n, err)
+		} else if _, err := bolt.OpenReaderAt(bytes.NewReader(data), n); err == nil {
+			t.Fatalf("%d: expected error", n)
+		}
+	}
+
+	// Data that ends at the high water mark can be opened.
+	mdb, err := bolt.OpenBytes(data[:size])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mdb.Close()
+	if err := mdb.View(func(tx *bolt.Tx) error {
+		for err := range tx.Check() {
+			t.Fatal(err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure that a short read from the reader returns an error.
+func TestOpenReaderAt_ShortRead(t *testing.T) {
+	r := bytes.NewReader(make([]byte, 100))
+	if _, err := bolt.OpenReaderAt(r, 200); err != io.EOF {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
 // TestDB_Open_InitialMmapSize tests if having InitialMmapSize large enough
 // to hold data from concurrent write transaction resolves the issue that
 // read transaction blocks the write transaction and causes deadlock.
@@ -408,17 +772,439 @@ func TestDB_Open_InitialMmapSize(t *testing.T) {
 		if err := wtx.Commit(); err != nil {
 			t.Fatal(err)
 		}
-		done <- struct{}{}
-	}()
+		done <- struct{}{}
+	}()
+
+	select {
+	case <-time.After(5 * time.Second):
+		t.Errorf("unexpected that the reader blocks writer")
+	case <-done:
+	}
+
+	if err := rtx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure that the tracer is notified when the data file is remapped.
+func TestDB_Tracer_Remap(t *testing.T) {
+	var tracer testTracer
+	db, err := bolt.Open(tempfile(), 0666, &bolt.Options{Tracer: &tracer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(db.Path())
+	defer db.Close()
+
+	// Insert enough data to grow the mmap beyond its initial size.
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 1000; i++ {
+			if err := b.Put(u64tob(uint64(i)), make([]byte, 500)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	for _, e := range tracer.Events() {
+		if e == "remap" {
+			n++
+		}
+	}
+	if n < 2 {
+		t.Fatalf("expected at least 2 remaps, got %d", n)
+	}
+}
+
+// Ensure that the mmap grows according to a fixed step policy.
+func TestDB_MmapGrowth_Step(t *testing.T) {
+	db, err := bolt.Open(tempfile(), 0666, &bolt.Options{MmapGrowth: &bolt.MmapGrowth{Step: 1 << 20}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(db.Path())
+	defer db.Close()
+
+	if stats := db.Stats(); stats.MmapSize != 1<<20 {
+		t.Fatalf("unexpected initial mmap size: %d", stats.MmapSize)
+	} else if stats.RemapN != 0 {
+		t.Fatalf("unexpected initial remap count: %d", stats.RemapN)
+	}
+
+	// Write enough data to exceed the first step.
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 3000; i++ {
+			if err := b.Put(u64tob(uint64(i)), make([]byte, 500)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if stats := db.Stats(); stats.MmapSize <= 1<<20 || stats.MmapSize%(1<<20) != 0 {
+		t.Fatalf("unexpected mmap size: %d", stats.MmapSize)
+	} else if stats.RemapN == 0 {
+		t.Fatalf("unexpected remap count: %d", stats.RemapN)
+	} else if stats.RemapTime <= 0 {
+		t.Fatalf("unexpected remap time: %s", stats.RemapTime)
+	}
+}
+
+// Ensure that a percent growth policy is limited by the max size but keeps
+// growing by a percentage once the data file is past it.
+func TestDB_MmapGrowth_PercentMaxSize(t *testing.T) {
+	path := tempfile()
+	defer os.Remove(path)
+
+	// Create a database larger than the max size.
+	db, err := bolt.Open(path, 0666, &bolt.Options{InitialMmapSize: 1 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 1000; i++ {
+			if err := b.Put(u64tob(uint64(i)), make([]byte, 500)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	} else if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	sz := int(fileSize(path))
+
+	// Reopen with a policy that would reserve twice the file size.
+	db, err = bolt.Open(path, 0666, &bolt.Options{MmapGrowth: &bolt.MmapGrowth{Percent: 100}})
+	if err != nil {
+		t.Fatal(err)
+	} else if n := db.Stats().MmapSize; n != 2*sz {
+		t.Fatalf("unexpected mmap size: %d (file size %d)", n, sz)
+	} else if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopen with a max size below the file size.
+	db, err = bolt.Open(path, 0666, &bolt.Options{MmapGrowth: &bolt.MmapGrowth{Percent: 100, MaxSize: 1 << 16}})
+	if err != nil {
+		t.Fatal(err)
+	} else if n := db.Stats().MmapSize; n != 2*sz {
+		t.Fatalf("unexpected mmap size: %d (file size %d)", n, sz)
+	} else if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopen with a max size below the file size and a step.
+	db, err = bolt.Open(path, 0666, &bolt.Options{MmapGrowth: &bolt.MmapGrowth{Step: 1 << 20, Percent: 100, MaxSize: 1 << 16}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if n := db.Stats().MmapSize; n < sz || n%(1<<20) != 0 || n-sz >= 1<<20 {
+		t.Fatalf("unexpected mmap size: %d (file size %d)", n, sz)
+	}
+}
+
+// Ensure that the mmap keeps growing in steps once it is past the max size
+// instead of being remapped for every allocation.
+func TestDB_MmapGrowth_PastMaxSize(t *testing.T) {
+	const step, maxSize = 1 << 18, 1 << 17
+	db, err := bolt.Open(tempfile(), 0666, &bolt.Options{MmapGrowth: &bolt.MmapGrowth{Step: step, Percent: 100, MaxSize: maxSize}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(db.Path())
+	defer db.Close()
+
+	// Grow the file one page at a time so that every allocation past the
+	// current map requires a remap.
+	db.AllocSize = os.Getpagesize()
+
+	var n int
+	for i := 0; i < 500; i++ {
+		if err := db.Update(func(tx *bolt.Tx) error {
+			b, err := tx.CreateBucketIfNotExists([]byte("widgets"))
+			if err != nil {
+				return err
+			}
+			return b.Put(u64tob(uint64(i)), make([]byte, 2000))
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		if sz := db.Stats().MmapSize; sz > maxSize {
+			n++
+			if sz%step != 0 {
+				t.Fatalf("unexpected mmap size: %d", sz)
+			}
+		}
+	}
+	if n == 0 {
+		t.Fatal("expected mmap past max size")
+	} else if stats := db.Stats(); stats.MmapSize < 4*step || stats.RemapN > 10 {
+		t.Fatalf("unexpected mmap size %d after %d remaps", stats.MmapSize, stats.RemapN)
+	}
+}
+
+// Ensure that a policy with only a max size keeps the default doubling
+// growth instead of remapping for every allocation.
+func TestDB_MmapGrowth_MaxSizeOnly(t *testing.T) {
+	const maxSize = 1 << 17
+	grow := func(g *bolt.MmapGrowth) bolt.Stats {
+		db, err := bolt.Open(tempfile(), 0666, &bolt.Options{MmapGrowth: g})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(db.Path())
+		defer db.Close()
+
+		// Grow the file one page at a time so that the map is remapped
+		// whenever an allocation passes its end.
+		db.AllocSize = os.Getpagesize()
+		for i := 0; i < 500; i++ {
+			if err := db.Update(func(tx *bolt.Tx) error {
+				b, err := tx.CreateBucketIfNotExists([]byte("widgets"))
+				if err != nil {
+					return err
+				}
+				return b.Put(u64tob(uint64(i)), make([]byte, 2000))
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return db.Stats()
+	}
+
+	def, stats := grow(nil), grow(&bolt.MmapGrowth{MaxSize: maxSize})
+	if stats.MmapSize != def.MmapSize || stats.MmapSize <= maxSize {
+		t.Fatalf("unexpected mmap size: %d, default %d", stats.MmapSize, def.MmapSize)
+	} else if stats.RemapN > def.RemapN {
+		t.Fatalf("unexpected remap count: %d, default %d", stats.RemapN, def.RemapN)
+	}
+}
+
+// Ensure that prefix compression stores keys with shared prefixes in fewer
+// pages and that the setting persists after reopening.
+func TestDB_PrefixCompression(t *testing.T) {
+	key := func(i int) []byte { return []byte(fmt.Sprintf("tenants/00000000000000000042/objects/%08d", i)) }
+	fill := func(db *bolt.DB) int {
+		if err := db.Update(func(tx *bolt.Tx) error {
+			b, err := tx.CreateBucket([]byte("widgets"))
+			if err != nil {
+				return err
+			}
+			for i := 0; i < 5000; i++ {
+				if err := b.Put(key(i), []byte("value")); err != nil {
+					return err
+				}
+			}
+			_, err = b.CreateBucket(key(2500)[:len(key(2500))-1])
+			return err
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		var n int
+		if err := db.View(func(tx *bolt.Tx) error {
+			n = tx.Bucket([]byte("widgets")).Stats().LeafPageN
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	plain := MustOpenDB()
+	defer plain.MustClose()
+	plainN := fill(plain.DB)
+
+	path := tempfile()
+	defer os.Remove(path)
+	db, err := bolt.Open(path, 0666, &bolt.Options{PrefixCompression: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := fill(db); n >= plainN/2 {
+		t.Fatalf("expected fewer leaf pages: %d >= %d/2", n, plainN)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopen without the option and continue writing compressed pages.
+	db, err = bolt.Open(path, 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("widgets"))
+		if err := b.Delete(key(100)); err != nil {
+			return err
+		}
+		return b.Put([]byte("other"), []byte("value"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("widgets"))
+		if v := b.Get(key(4999)); !bytes.Equal(v, []byte("value")) {
+			t.Fatalf("unexpected value: %q", v)
+		} else if v := b.Get(key(100)); v != nil {
+			t.Fatalf("unexpected value: %q", v)
+		} else if b.Bucket(key(2500)[:len(key(2500))-1]) == nil {
+			t.Fatal("expected nested bucket")
+		}
+
+		// Seek before, within and after the shared prefix.
+		c := b.Cursor()
+		if k, _ := c.Seek([]byte("tenants/")); !bytes.Equal(k, key(0)) {
+			t.Fatalf("unexpected key: %s", k)
+		} else if k, _ := c.Seek(key(1234)); !bytes.Equal(k, key(1234)) {
+			t.Fatalf("unexpected key: %s", k)
+		} else if k, _ := c.Seek([]byte("tenants/1")); k != nil {
+			t.Fatalf("unexpected key: %s", k)
+		} else if k, _ := c.First(); !bytes.Equal(k, []byte("other")) {
+			t.Fatalf("unexpected key: %s", k)
+		}
+
+		var n int
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			n++
+		}
+		if n != 5001 {
+			t.Fatalf("unexpected key count: %d", n)
+		}
+		for err := range tx.Check() {
+			t.Fatal(err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure that keys read from compressed pages remain valid after the cursor
+// moves and that iterating doesn't allocate for every key.
+func TestDB_PrefixCompression_Keys(t *testing.T) {
+	db, err := bolt.Open(tempfile(), 0666, &bolt.Options{PrefixCompression: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(db.Path())
+	defer db.Close()
+
+	const count = 1000
+	key := func(i int) []byte { return []byte(fmt.Sprintf("tenants/00000000000000000042/objects/%08d", i)) }
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			return err
+		}
+		for i := 0; i < count; i++ {
+			if err := b.Put(key(i), nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.View(func(tx *bolt.Tx) error {
+		var keys [][]byte
+		c := tx.Bucket([]byte("widgets")).Cursor()
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			keys = append(keys, k)
+
+			// Appending to a key must not change the next one.
+			_ = append(k, 'x')
+		}
+		if len(keys) != count {
+			t.Fatalf("unexpected key count: %d", len(keys))
+		}
+		for i, k := range keys {
+			if !bytes.Equal(k, key(i)) {
+				t.Fatalf("unexpected key: %s", k)
+			}
+		}
+
+		if n := testing.AllocsPerRun(10, func() {
+			for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			}
+		}); n > count/10 {
+			t.Fatalf("unexpected allocations: %v", n)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure that a nested bucket which only fits inline once its keys are prefix
+// compressed is stored inline.
+func TestDB_PrefixCompression_Inline(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		db, err := bolt.Open(tempfile(), 0666, &bolt.Options{PrefixCompression: compress})
+		if err != nil {
+			t.Fatal(err)
+		}
 
-	select {
-	case <-time.After(5 * time.Second):
-		t.Errorf("unexpected that the reader blocks writer")
-	case <-done:
-	}
+		// Uncompressed, the keys take more than a quarter of a page.
+		n := os.Getpagesize() / 160
+		if err := db.Update(func(tx *bolt.Tx) error {
+			b, err := tx.CreateBucket([]byte("widgets"))
+			if err != nil {
+				return err
+			}
+			child, err := b.CreateBucket([]byte("child"))
+			if err != nil {
+				return err
+			}
+			for i := 0; i < n; i++ {
+				if err := child.Put([]byte(fmt.Sprintf("tenants/00000000000000000042/objects/%05d", i)), nil); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
 
-	if err := rtx.Rollback(); err != nil {
-		t.Fatal(err)
+		if err := db.View(func(tx *bolt.Tx) error {
+			if stats := tx.Bucket([]byte("widgets")).Stats(); compress && stats.InlineBucketN != 1 {
+				t.Fatalf("expected inline bucket: %+v", stats)
+			} else if !compress && stats.InlineBucketN != 0 {
+				t.Fatalf("unexpected inline bucket: %+v", stats)
+			}
+			for err := range tx.Check() {
+				t.Fatal(err)
+			}
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		db.Close()
+		os.Remove(db.Path())
 	}
 }
 
@@ -779,6 +1565,73 @@ func TestDB_Stats(t *testing.T) {
 	}
 }
 
+// Ensure that freelist stats report free and pending pages.
+func TestDB_FreelistStats(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 1000; i++ {
+			if err := b.Put([]byte(fmt.Sprintf("%04d", i)), make([]byte, 100)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Keep a reader open so the pages freed by the deletion stay pending.
+	tx, err := db.Begin(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		return tx.DeleteBucket([]byte("widgets"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := db.FreelistStats()
+	if err != nil {
+		t.Fatal(err)
+	} else if s.PendingPageN < 25 || len(s.Pending) != 2 || s.Pending[0].PageN+s.Pending[1].PageN != s.PendingPageN {
+		t.Fatalf("unexpected pending pages: %+v", s)
+	} else if s.Pending[1].TxID != tx.ID()+1 || s.Pending[1].PageN < 25 {
+		t.Fatalf("unexpected pending pages: %+v", s)
+	}
+
+	// Release the pages with a new write transaction.
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	} else if err := db.Update(func(tx *bolt.Tx) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = db.FreelistStats()
+	if err != nil {
+		t.Fatal(err)
+	} else if s.FreePageN < 25 || s.ExtentN == 0 || s.LargestExtentN == 0 || s.LargestExtentN > s.FreePageN {
+		t.Fatalf("unexpected free pages: %+v", s)
+	} else if s.TailFreePageN > s.LargestExtentN || s.PageN <= s.FreePageN {
+		t.Fatalf("unexpected tail: %+v", s)
+	}
+
+	var n int
+	for i, c := range s.ExtentSizes {
+		n += c
+		if c > 0 && 1<<uint(i) > s.LargestExtentN {
+			t.Fatalf("unexpected histogram: %+v", s)
+		}
+	}
+	if n != s.ExtentN {
+		t.Fatalf("unexpected histogram: %+v", s)
+	}
+}
+
 // Ensure that database pages are in expected order and type.
 func TestDB_Consistency(t *testing.T) {
 	db := MustOpenDB()
@@ -942,6 +1795,30 @@ func TestDB_Batch_Panic(t *testing.T) {
 	}
 }
 
+// Ensure that a panic inside a batch function is reported to the logger.
+func TestDB_Batch_Panic_Logger(t *testing.T) {
+	var logger testLogger
+	db, err := bolt.Open(tempfile(), 0666, &bolt.Options{Logger: &logger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(db.Path())
+	defer db.Close()
+
+	func() {
+		defer func() { _ = recover() }()
+		_ = db.Batch(func(tx *bolt.Tx) error {
+			panic("boom")
+		})
+	}()
+
+	if msgs := logger.Messages("ERROR"); len(msgs) != 1 {
+		t.Fatalf("unexpected error messages: %q", msgs)
+	} else if !strings.Contains(msgs[0], "boom") {
+		t.Fatalf("unexpected error message: %q", msgs[0])
+	}
+}
+
 func TestDB_BatchFull(t *testing.T) {
 	db := MustOpenDB()
 	defer db.MustClose()
@@ -1048,6 +1925,276 @@ func TestDB_BatchTime(t *testing.T) {
 	}
 }
 
+// Ensure that BatchValue returns per-call values and records batch stats.
+func TestDB_BatchValue(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucket([]byte("widgets"))
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	db.MaxBatchSize = 3
+	db.MaxBatchDelay = 1 * time.Hour
+
+	type result struct {
+		i     int
+		value interface{}
+		err   error
+	}
+	ch := make(chan result, db.MaxBatchSize)
+	for i := 0; i < db.MaxBatchSize; i++ {
+		go func(i int) {
+			v, err := db.BatchValue(func(tx *bolt.Tx) (interface{}, error) {
+				if i == 2 {
+					return nil, errors.New("marker")
+				}
+				return tx.Bucket([]byte("widgets")).NextSequence()
+			})
+			ch <- result{i, v, err}
+		}(i)
+	}
+
+	seqs := make(map[uint64]bool)
+	for i := 0; i < db.MaxBatchSize; i++ {
+		r := <-ch
+		if r.i == 2 {
+			if r.err == nil || r.err.Error() != "marker" {
+				t.Fatalf("unexpected error: %v", r.err)
+			}
+			continue
+		} else if r.err != nil {
+			t.Fatal(r.err)
+		}
+		seqs[r.value.(uint64)] = true
+	}
+	if !seqs[1] || !seqs[2] {
+		t.Fatalf("unexpected sequences: %v", seqs)
+	}
+
+	stats := db.Stats()
+	if stats.BatchN != 1 {
+		t.Fatalf("unexpected BatchN: %d", stats.BatchN)
+	} else if stats.BatchCallN != 2 {
+		t.Fatalf("unexpected BatchCallN: %d", stats.BatchCallN)
+	} else if stats.BatchSoloN != 1 {
+		t.Fatalf("unexpected BatchSoloN: %d", stats.BatchSoloN)
+	} else if stats.BatchWait <= 0 {
+		t.Fatalf("unexpected BatchWait: %s", stats.BatchWait)
+	}
+}
+
+// Ensure that batches are split into several transactions by MaxBatchBytes.
+func TestDB_Batch_MaxBatchBytes(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucket([]byte("widgets"))
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	const size = 4
+	db.MaxBatchSize = size
+	db.MaxBatchDelay = 1 * time.Hour
+	db.MaxBatchBytes = 100
+
+	// Each call puts 68 bytes so only two calls fit in a transaction.
+	ch := make(chan error, size)
+	for i := 0; i < size; i++ {
+		go func(i int) {
+			ch <- db.Batch(func(tx *bolt.Tx) error {
+				time.Sleep(10 * time.Millisecond)
+				return tx.Bucket([]byte("widgets")).Put(u64tob(uint64(i)), make([]byte, 60))
+			})
+		}(i)
+	}
+	for i := 0; i < size; i++ {
+		if err := <-ch; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := db.View(func(tx *bolt.Tx) error {
+		if n := tx.Bucket([]byte("widgets")).Stats().KeyN; n != size {
+			t.Fatalf("unexpected key count: %d", n)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := db.Stats()
+	if stats.BatchN != 2 {
+		t.Fatalf("unexpected BatchN: %d", stats.BatchN)
+	} else if stats.BatchCallN != size {
+		t.Fatalf("unexpected BatchCallN: %d", stats.BatchCallN)
+	} else if stats.BatchSplitN != 1 {
+		t.Fatalf("unexpected BatchSplitN: %d", stats.BatchSplitN)
+	}
+
+	// The first two calls wait for two calls to run and the last two
+	// wait for all four, so the total wait is at least 12 calls long.
+	if stats.BatchWait < 120*time.Millisecond {
+		t.Fatalf("unexpected BatchWait: %s", stats.BatchWait)
+	}
+}
+
+// Ensure that batches which fail to commit are not counted.
+func TestDB_Batch_Failed(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+
+	data, err := ioutil.ReadFile(db.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mdb, err := bolt.OpenBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mdb.Close()
+
+	if err := mdb.Batch(func(tx *bolt.Tx) error { return nil }); err != bolt.ErrDatabaseReadOnly {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stats := mdb.Stats()
+	if stats.BatchN != 0 {
+		t.Fatalf("unexpected BatchN: %d", stats.BatchN)
+	} else if stats.BatchCallN != 0 {
+		t.Fatalf("unexpected BatchCallN: %d", stats.BatchCallN)
+	} else if stats.BatchWait != 0 {
+		t.Fatalf("unexpected BatchWait: %s", stats.BatchWait)
+	}
+}
+
+// Ensure that deletes through CompareAndSwap count towards MaxBatchBytes.
+func TestDB_Batch_MaxBatchBytes_CompareAndSwap(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+
+	const size = 4
+	key := func(i int) []byte { return []byte(fmt.Sprintf("%060d", i)) }
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			return err
+		}
+		for i := 0; i < size; i++ {
+			if err := b.Put(key(i), []byte("x")); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	db.MaxBatchSize = size
+	db.MaxBatchDelay = 1 * time.Hour
+	db.MaxBatchBytes = 100
+
+	// Each call deletes a 60 byte key so only two calls fit in a transaction.
+	ch := make(chan error, size)
+	for i := 0; i < size; i++ {
+		go func(i int) {
+			ch <- db.Batch(func(tx *bolt.Tx) error {
+				return tx.Bucket([]byte("widgets")).CompareAndSwap(key(i), []byte("x"), nil)
+			})
+		}(i)
+	}
+	for i := 0; i < size; i++ {
+		if err := <-ch; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := db.View(func(tx *bolt.Tx) error {
+		if n := tx.Bucket([]byte("widgets")).Stats().KeyN; n != 0 {
+			t.Fatalf("unexpected key count: %d", n)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if stats := db.Stats(); stats.BatchN != 2 {
+		t.Fatalf("unexpected BatchN: %d", stats.BatchN)
+	} else if stats.BatchSplitN != 1 {
+		t.Fatalf("unexpected BatchSplitN: %d", stats.BatchSplitN)
+	}
+}
+
+// Ensure that deletes count towards MaxBatchBytes in BatchValue.
+func TestDB_BatchValue_Delete(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+
+	const size = 4
+	key := func(i int) []byte { return []byte(fmt.Sprintf("%060d", i)) }
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			return err
+		}
+		for i := 0; i < size; i++ {
+			if err := b.Put(key(i), []byte("x")); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	db.MaxBatchSize = size
+	db.MaxBatchDelay = 1 * time.Hour
+	db.MaxBatchBytes = 100
+
+	// Each call deletes a 60 byte key so only two calls fit in a transaction.
+	ch := make(chan error, size)
+	for i := 0; i < size; i++ {
+		go func(i int) {
+			v, err := db.BatchValue(func(tx *bolt.Tx) (interface{}, error) {
+				b := tx.Bucket([]byte("widgets"))
+				v := string(b.Get(key(i)))
+				return v, b.Delete(key(i))
+			})
+			if err == nil && v != "x" {
+				err = fmt.Errorf("unexpected value: %v", v)
+			}
+			ch <- err
+		}(i)
+	}
+	for i := 0; i < size; i++ {
+		if err := <-ch; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := db.View(func(tx *bolt.Tx) error {
+		if n := tx.Bucket([]byte("widgets")).Stats().KeyN; n != 0 {
+			t.Fatalf("unexpected key count: %d", n)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := db.Stats()
+	if stats.BatchN != 2 {
+		t.Fatalf("unexpected BatchN: %d", stats.BatchN)
+	} else if stats.BatchCallN != size {
+		t.Fatalf("unexpected BatchCallN: %d", stats.BatchCallN)
+	} else if stats.BatchSplitN != 1 {
+		t.Fatalf("unexpected BatchSplitN: %d", stats.BatchSplitN)
+	}
+}
+
 func ExampleDB_Update() {
 	// Open the database.
 	db, err := bolt.Open(tempfile(), 0666, nil)
@@ -1463,6 +2610,33 @@ func (db *DB) CopyTempFile() {
 	fmt.Println("db copied to: ", path)
 }
 
+// testLogger is a bolt.Logger that records messages by level.
+type testLogger struct {
+	mu   sync.Mutex
+	msgs map[string][]string
+}
+
+func (l *testLogger) Debugf(format string, v ...interface{})   { l.log("DEBUG", format, v...) }
+func (l *testLogger) Infof(format string, v ...interface{})    { l.log("INFO", format, v...) }
+func (l *testLogger) Warningf(format string, v ...interface{}) { l.log("WARNING", format, v...) }
+func (l *testLogger) Errorf(format string, v ...interface{})   { l.log("ERROR", format, v...) }
+
+func (l *testLogger) log(level, format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.msgs == nil {
+		l.msgs = make(map[string][]string)
+	}
+	l.msgs[level] = append(l.msgs[level], fmt.Sprintf(format, v...))
+}
+
+// Messages returns all messages logged at a given level.
+func (l *testLogger) Messages(level string) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.msgs[level]
+}
+
 // tempfile returns a temporary file path.
 func tempfile() string {
 	f, err := ioutil.TempFile("", "bolt-")
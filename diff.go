@@ -0,0 +1,144 @@
+package bolt
+
+import "bytes"
+
+// DiffType is the kind of change reported by Diff.
+type DiffType int
+
+const (
+	// DiffAdded means the key or bucket only exists in the to transaction.
+	DiffAdded DiffType = iota + 1
+
+	// DiffRemoved means the key or bucket only exists in the from transaction.
+	DiffRemoved
+
+	// DiffModified means the key exists in both transactions with different
+	// values.
+	DiffModified
+
+	// DiffSequence means the bucket exists in both transactions with
+	// different sequences.
+	DiffSequence
+)
+
+// String returns the name of the change.
+func (t DiffType) String() string {
+	switch t {
+	case DiffAdded:
+		return "added"
+	case DiffRemoved:
+		return "removed"
+	case DiffModified:
+		return "modified"
+	case DiffSequence:
+		return "sequence"
+	default:
+		return "unknown"
+	}
+}
+
+// Difference represents a single change between two transactions.
+//
+// Path is the path of the bucket holding Key, which is empty for root
+// buckets. If Bucket is true then Key is a nested bucket and the values are
+// nil. When a bucket is added or removed its contents are not reported
+// separately. A key that changes between a value and a bucket is reported as
+// a removal followed by an addition.
+type Difference struct {
+	Type     DiffType
+	Path     [][]byte
+	Key      []byte
+	Bucket   bool
+	OldValue []byte
+	NewValue []byte
+
+	OldSequence uint64 // only set for DiffSequence
+	NewSequence uint64 // only set for DiffSequence
+}
+
+// Diff compares every bucket in from with to, which are usually read-only
+// transactions on two databases, and calls fn for each difference. Buckets
+// are walked in key order and nested buckets are compared after the key that
+// holds them. If fn returns an error then the walk stops and the error is
+// returned.
+//
+// The fields of the Difference are only valid for the duration of the call.
+func Diff(from, to *Tx, fn func(d Difference) error) error {
+	if from.db == nil || to.db == nil {
+		return ErrTxClosed
+	}
+	return diffBuckets(nil, &from.root, &to.root, fn)
+}
+
+// diffBuckets reports the differences between two buckets at path.
+func diffBuckets(path [][]byte, a, b *Bucket, fn func(d Difference) error) error {
+	ca, cb := a.Cursor(), b.Cursor()
+	ka, va := ca.First()
+	kb, vb := cb.First()
+	for ka != nil || kb != nil {
+		cmp := -1
+		if ka == nil {
+			cmp = 1
+		} else if kb != nil {
+			cmp = bytes.Compare(ka, kb)
+		}
+
+		// Look buckets up by key since an empty value can also be nil.
+		var ba, bb *Bucket
+		if cmp <= 0 {
+			ba = a.Bucket(ka)
+		}
+		if cmp >= 0 {
+			bb = b.Bucket(kb)
+		}
+
+		switch {
+		case cmp < 0:
+			if err := fn(Difference{Type: DiffRemoved, Path: path, Key: ka, Bucket: ba != nil, OldValue: va}); err != nil {
+				return err
+			}
+			ka, va = ca.Next()
+			continue
+
+		case cmp > 0:
+			if err := fn(Difference{Type: DiffAdded, Path: path, Key: kb, Bucket: bb != nil, NewValue: vb}); err != nil {
+				return err
+			}
+			kb, vb = cb.Next()
+			continue
+
+		case ba == nil && bb == nil:
+			if !bytes.Equal(va, vb) {
+				if err := fn(Difference{Type: DiffModified, Path: path, Key: ka, OldValue: va, NewValue: vb}); err != nil {
+					return err
+				}
+			}
+
+		case ba != nil && bb != nil:
+			if ba.Sequence() != bb.Sequence() {
+				if err := fn(Difference{Type: DiffSequence, Path: path, Key: ka, Bucket: true, OldSequence: ba.Sequence(), NewSequence: bb.Sequence()}); err != nil {
+					return err
+				}
+			}
+
+			// Copy the path so that callers never see it change.
+			child := make([][]byte, len(path)+1)
+			copy(child, path)
+			child[len(path)] = ka
+			if err := diffBuckets(child, ba, bb, fn); err != nil {
+				return err
+			}
+
+		default:
+			if err := fn(Difference{Type: DiffRemoved, Path: path, Key: ka, Bucket: ba != nil, OldValue: va}); err != nil {
+				return err
+			} else if err := fn(Difference{Type: DiffAdded, Path: path, Key: kb, Bucket: bb != nil, NewValue: vb}); err != nil {
+				return err
+			}
+		}
+
+		ka, va = ca.Next()
+		kb, vb = cb.Next()
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package bolt_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+// Ensure that Diff reports added, removed and modified keys and buckets.
+func TestDiff(t *testing.T) {
+	a, b := MustOpenDB(), MustOpenDB()
+	defer a.MustClose()
+	defer b.MustClose()
+
+	if err := a.Update(func(tx *bolt.Tx) error {
+		users, _ := tx.CreateBucket([]byte("users"))
+		_ = users.SetSequence(1)
+		_ = users.Put([]byte("alice"), []byte("1"))
+		_ = users.Put([]byte("bob"), []byte("2"))
+		_ = users.Put([]byte("carol"), []byte("3"))
+		_ = users.Put([]byte("dave"), []byte("4"))
+		_ = users.Put([]byte("zed"), []byte("z"))
+		admins, _ := users.CreateBucket([]byte("admins"))
+		_ = admins.Put([]byte("root"), []byte("x"))
+		_, _ = tx.CreateBucket([]byte("old"))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Update(func(tx *bolt.Tx) error {
+		users, _ := tx.CreateBucket([]byte("users"))
+		_ = users.SetSequence(2)
+		_ = users.Put([]byte("alice"), []byte("1"))
+		_ = users.Put([]byte("carol"), []byte("30"))
+		_, _ = users.CreateBucket([]byte("dave"))
+		_ = users.Put([]byte("erin"), []byte("5"))
+		admins, _ := users.CreateBucket([]byte("admins"))
+		_ = admins.Put([]byte("root"), []byte("y"))
+		_, _ = tx.CreateBucket([]byte("new"))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var diffs []string
+	if err := a.View(func(atx *bolt.Tx) error {
+		return b.View(func(btx *bolt.Tx) error {
+			return bolt.Diff(atx, btx, func(d bolt.Difference) error {
+				diffs = append(diffs, fmt.Sprintf("%s %s/%s %v %s->%s %d->%d", d.Type, bytesJoin(d.Path), d.Key, d.Bucket, d.OldValue, d.NewValue, d.OldSequence, d.NewSequence))
+				return nil
+			})
+		})
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{
+		"added /new true -> 0->0",
+		"removed /old true -> 0->0",
+		"sequence /users true -> 1->2",
+		"modified users/admins/root false x->y 0->0",
+		"removed users/bob false 2-> 0->0",
+		"modified users/carol false 3->30 0->0",
+		"removed users/dave false 4-> 0->0",
+		"added users/dave true -> 0->0",
+		"added users/erin false ->5 0->0",
+		"removed users/zed false z-> 0->0",
+	}
+	if !reflect.DeepEqual(diffs, exp) {
+		t.Fatalf("unexpected differences:\n%s", strings.Join(diffs, "\n"))
+	}
+}
+
+// Ensure that Diff stops when the callback returns an error.
+func TestDiff_ErrCallback(t *testing.T) {
+	a, b := MustOpenDB(), MustOpenDB()
+	defer a.MustClose()
+	defer b.MustClose()
+
+	if err := b.Update(func(tx *bolt.Tx) error {
+		_, _ = tx.CreateBucket([]byte("x"))
+		_, _ = tx.CreateBucket([]byte("y"))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	n, errStop := 0, errors.New("stop")
+	if err := a.View(func(atx *bolt.Tx) error {
+		return b.View(func(btx *bolt.Tx) error {
+			return bolt.Diff(atx, btx, func(d bolt.Difference) error {
+				n++
+				return errStop
+			})
+		})
+	}); err != errStop {
+		t.Fatalf("unexpected error: %v", err)
+	} else if n != 1 {
+		t.Fatalf("unexpected callback count: %d", n)
+	}
+}
+
+// bytesJoin joins a bucket path with slashes.
+func bytesJoin(path [][]byte) string {
+	s := make([]string, len(path))
+	for i, p := range path {
+		s[i] = string(p)
+	}
+	return strings.Join(s, "/")
+}
+
+// Ensure that Diff does not mistake keys with nil values for buckets.
+func TestDiff_NilValue(t *testing.T) {
+	a, b := MustOpenDB(), MustOpenDB()
+	defer a.MustClose()
+	defer b.MustClose()
+
+	if err := b.Update(func(tx *bolt.Tx) error {
+		widgets, _ := tx.CreateBucket([]byte("widgets"))
+		_, _ = widgets.CreateBucket([]byte("foo"))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Keys put with a nil value are returned as nil until they are committed.
+	var diffs []string
+	if err := a.Update(func(atx *bolt.Tx) error {
+		widgets, _ := atx.CreateBucket([]byte("widgets"))
+		_ = widgets.Put([]byte("foo"), nil)
+		return b.View(func(btx *bolt.Tx) error {
+			return bolt.Diff(atx, btx, func(d bolt.Difference) error {
+				diffs = append(diffs, fmt.Sprintf("%s %s/%s %v", d.Type, bytesJoin(d.Path), d.Key, d.Bucket))
+				return nil
+			})
+		})
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{
+		"removed widgets/foo false",
+		"added widgets/foo true",
+	}
+	if !reflect.DeepEqual(diffs, exp) {
+		t.Fatalf("unexpected differences:\n%s", strings.Join(diffs, "\n"))
+	}
+}
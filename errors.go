@@ -1,6 +1,10 @@
 package bolt
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
 // These errors can be returned when opening or calling methods on a DB.
 var (
@@ -23,9 +27,22 @@ var (
 	// ErrChecksum is returned when either meta page checksum does not match.
 	ErrChecksum = errors.New("checksum error")
 
+	// ErrForeignEndian is returned when the data file was created on a
+	// machine with a different byte order. Use "bolt convert-endian" to
+	// convert it.
+	ErrForeignEndian = errors.New("database uses a different byte order")
+
 	// ErrTimeout is returned when a database cannot obtain an exclusive lock
 	// on the data file after the timeout passed to Open().
 	ErrTimeout = errors.New("timeout")
+
+	// ErrInvalidPageSize is returned when opening a database with a page
+	// size that is not a power of two between 1KB and 1MB.
+	ErrInvalidPageSize = errors.New("invalid page size")
+
+	// ErrShardsRequired is returned when opening a sharded database without
+	// any shard paths.
+	ErrShardsRequired = errors.New("at least one shard required")
 )
 
 // These errors can occur when beginning or committing a Tx.
@@ -68,4 +85,53 @@ var (
 	// on an existing non-bucket key or when trying to create or delete a
 	// non-bucket key on an existing bucket key.
 	ErrIncompatibleValue = errors.New("incompatible value")
+
+	// ErrValueMismatch is returned by CompareAndSwap when the current value
+	// does not match the expected value.
+	ErrValueMismatch = errors.New("value mismatch")
+
+	// ErrKeyExists is returned by PutIfAbsent when the key already exists.
+	ErrKeyExists = errors.New("key already exists")
+
+	// ErrInvalidCounter is returned by Increment when the existing value is
+	// not an 8-byte counter.
+	ErrInvalidCounter = errors.New("invalid counter")
+
+	// ErrCounterOverflow is returned by Increment when the result would
+	// overflow an int64.
+	ErrCounterOverflow = errors.New("counter overflow")
 )
+
+// PathError is returned by the bucket path methods on Tx. It records the
+// path up to and including the element that failed and the underlying error,
+// typically ErrBucketNotFound or ErrIncompatibleValue.
+type PathError struct {
+	Path [][]byte
+	Err  error
+}
+
+// Error returns the failing path and the underlying error.
+func (e *PathError) Error() string {
+	parts := make([]string, len(e.Path))
+	for i, name := range e.Path {
+		parts[i] = fmt.Sprintf("%q", name)
+	}
+	return fmt.Sprintf("bucket %s: %s", strings.Join(parts, "/"), e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *PathError) Unwrap() error { return e.Err }
+
+// FeatureError is returned when a data file uses required features that are
+// not supported by this version of Bolt. It wraps ErrVersionMismatch.
+type FeatureError struct {
+	Flags uint32 // unsupported feature flags
+}
+
+// Error returns the unsupported features in a readable form.
+func (e *FeatureError) Error() string {
+	return fmt.Sprintf("%s: unsupported features: %s", ErrVersionMismatch, strings.Join(features(e.Flags), ", "))
+}
+
+// Unwrap returns ErrVersionMismatch.
+func (e *FeatureError) Unwrap() error { return ErrVersionMismatch }
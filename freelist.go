@@ -2,6 +2,7 @@ package bolt
 
 import (
 	"fmt"
+	"math/bits"
 	"sort"
 	"unsafe"
 )
@@ -154,6 +155,46 @@ func (f *freelist) rollback(txid txid) {
 	delete(f.pending, txid)
 }
 
+// stats returns statistics about the free and pending pages given the high
+// water mark of the data file.
+func (f *freelist) stats(hwm pgid) FreelistStats {
+	s := FreelistStats{
+		PageN:        int(hwm),
+		FreePageN:    f.free_count(),
+		PendingPageN: f.pending_count(),
+	}
+
+	// Find the extents. The ids are sorted so each one ends at the first id
+	// that is not followed by the next page.
+	var n int
+	for i, id := range f.ids {
+		n++
+		if i+1 < len(f.ids) && f.ids[i+1] == id+1 {
+			continue
+		}
+
+		s.ExtentN++
+		if n > s.LargestExtentN {
+			s.LargestExtentN = n
+		}
+		b := bits.Len(uint(n)) - 1
+		for len(s.ExtentSizes) <= b {
+			s.ExtentSizes = append(s.ExtentSizes, 0)
+		}
+		s.ExtentSizes[b]++
+		if id == hwm-1 {
+			s.TailFreePageN = n
+		}
+		n = 0
+	}
+
+	for tid, ids := range f.pending {
+		s.Pending = append(s.Pending, PendingPages{TxID: int(tid), PageN: len(ids)})
+	}
+	sort.Slice(s.Pending, func(i, j int) bool { return s.Pending[i].TxID < s.Pending[j].TxID })
+	return s
+}
+
 // freed returns whether a given page is in the free list.
 func (f *freelist) freed(pgid pgid) bool {
 	return f.cache[pgid]
@@ -86,6 +86,33 @@ func TestFreelist_allocate(t *testing.T) {
 	}
 }
 
+// Ensure that freelist stats describe the extents of free pages.
+func TestFreelist_stats(t *testing.T) {
+	f := newFreelist()
+	f.ids = []pgid{3, 4, 5, 8, 10, 11, 12, 13}
+	f.pending[7] = []pgid{20}
+	f.pending[5] = []pgid{21, 22}
+
+	exp := FreelistStats{
+		PageN:          14,
+		FreePageN:      8,
+		PendingPageN:   3,
+		ExtentN:        3,
+		LargestExtentN: 4,
+		ExtentSizes:    []int{1, 1, 1},
+		TailFreePageN:  4,
+		Pending:        []PendingPages{{TxID: 5, PageN: 2}, {TxID: 7, PageN: 1}},
+	}
+	if s := f.stats(14); !reflect.DeepEqual(s, exp) {
+		t.Fatalf("exp=%+v; got=%+v", exp, s)
+	}
+
+	// Ensure free pages before the last page in use are not in the tail.
+	if s := f.stats(20); s.TailFreePageN != 0 {
+		t.Fatalf("unexpected tail: %d", s.TailFreePageN)
+	}
+}
+
 // Ensure that a freelist can deserialize from a freelist page.
 func TestFreelist_read(t *testing.T) {
 	// Create a page.
@@ -0,0 +1,83 @@
+package bolt
+
+import (
+	"fmt"
+	"log"
+)
+
+// Logger is the interface used by the database to report diagnostic
+// messages such as unmap failures, lock contention and strict mode errors.
+//
+// Messages are split into levels so that embedding applications can route
+// them to their own logging system. A Logger must be safe for concurrent use.
+type Logger interface {
+	Debugf(format string, v ...interface{})
+	Infof(format string, v ...interface{})
+	Warningf(format string, v ...interface{})
+	Errorf(format string, v ...interface{})
+}
+
+// discardLogger is the default logger. It drops all messages.
+type discardLogger struct{}
+
+func (discardLogger) Debugf(format string, v ...interface{})   {}
+func (discardLogger) Infof(format string, v ...interface{})    {}
+func (discardLogger) Warningf(format string, v ...interface{}) {}
+func (discardLogger) Errorf(format string, v ...interface{})   {}
+
+// StdLogger is a Logger that writes to a standard library *log.Logger.
+// Each message is prefixed with its level. Messages below Level are dropped.
+type StdLogger struct {
+	Logger *log.Logger
+	Level  LogLevel
+}
+
+// NewStdLogger returns a StdLogger that writes messages at or above level to l.
+func NewStdLogger(l *log.Logger, level LogLevel) *StdLogger {
+	return &StdLogger{Logger: l, Level: level}
+}
+
+// Debugf logs a message at the debug level.
+func (l *StdLogger) Debugf(format string, v ...interface{}) { l.output(LogDebug, format, v...) }
+
+// Infof logs a message at the info level.
+func (l *StdLogger) Infof(format string, v ...interface{}) { l.output(LogInfo, format, v...) }
+
+// Warningf logs a message at the warning level.
+func (l *StdLogger) Warningf(format string, v ...interface{}) { l.output(LogWarning, format, v...) }
+
+// Errorf logs a message at the error level.
+func (l *StdLogger) Errorf(format string, v ...interface{}) { l.output(LogError, format, v...) }
+
+func (l *StdLogger) output(level LogLevel, format string, v ...interface{}) {
+	if level < l.Level {
+		return
+	}
+	_ = l.Logger.Output(3, level.String()+": "+fmt.Sprintf(format, v...))
+}
+
+// LogLevel represents the severity of a logged message.
+type LogLevel int
+
+// Log levels in increasing order of severity.
+const (
+	LogDebug LogLevel = iota
+	LogInfo
+	LogWarning
+	LogError
+)
+
+// String returns the name of the level.
+func (l LogLevel) String() string {
+	switch l {
+	case LogDebug:
+		return "DEBUG"
+	case LogInfo:
+		return "INFO"
+	case LogWarning:
+		return "WARNING"
+	case LogError:
+		return "ERROR"
+	}
+	return fmt.Sprintf("LogLevel(%d)", int(l))
+}
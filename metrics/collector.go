@@ -0,0 +1,170 @@
+package metrics
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// Transaction phases recorded by a Collector.
+const (
+	PhaseLock      = "lock"
+	PhaseRebalance = "rebalance"
+	PhaseSpill     = "spill"
+	PhaseFreelist  = "freelist"
+	PhaseWrite     = "write"
+	PhaseSync      = "sync"
+	PhaseMeta      = "meta"
+)
+
+// phases lists every phase in the order they occur during a commit.
+var phases = []string{PhaseLock, PhaseRebalance, PhaseSpill, PhaseFreelist, PhaseWrite, PhaseSync, PhaseMeta}
+
+// Collector gathers metrics for a single database.
+//
+// Collector implements bolt.Tracer so that it can record the latency of
+// individual commits and their phases. Pass it as Options.Tracer when opening
+// the database and then call Attach with the opened database:
+//
+//	c := metrics.NewCollector(metrics.Labels{"db": "users"})
+//	db, err := bolt.Open(path, 0600, &bolt.Options{Tracer: c})
+//	...
+//	c.Attach(db)
+//
+// Gauges and counters taken from DB.Stats() are available without using the
+// collector as a tracer but the latency histograms will remain empty.
+type Collector struct {
+	bolt.NopTracer
+
+	labels Labels
+
+	mu sync.Mutex
+	db *bolt.DB
+
+	commit       *Histogram
+	commitErrorN uint64
+	phases       map[string]*Histogram
+	remap        *Histogram
+}
+
+// NewCollector returns a new collector identified by the given labels.
+func NewCollector(labels Labels) *Collector {
+	c := &Collector{
+		labels: labels.clone(),
+		commit: NewHistogram(nil),
+		phases: make(map[string]*Histogram, len(phases)),
+		remap:  NewHistogram(nil),
+	}
+	for _, phase := range phases {
+		c.phases[phase] = NewHistogram(nil)
+	}
+	return c
+}
+
+// Labels returns the labels identifying the collector.
+func (c *Collector) Labels() Labels {
+	return c.labels.clone()
+}
+
+// Attach associates an open database with the collector.
+// Passing nil detaches the current database.
+func (c *Collector) Attach(db *bolt.DB) {
+	c.mu.Lock()
+	c.db = db
+	c.mu.Unlock()
+}
+
+// TxLockAcquired records the time a writable transaction waited for the writer lock.
+func (c *Collector) TxLockAcquired(tx *bolt.Tx, wait time.Duration) {
+	if tx.Writable() {
+		c.phases[PhaseLock].Observe(wait)
+	}
+}
+
+// TxRebalance records the time spent rebalancing nodes.
+func (c *Collector) TxRebalance(tx *bolt.Tx, d time.Duration, n int) {
+	c.phases[PhaseRebalance].Observe(d)
+}
+
+// TxSpill records the time spent spilling nodes onto dirty pages.
+func (c *Collector) TxSpill(tx *bolt.Tx, d time.Duration, n int) {
+	c.phases[PhaseSpill].Observe(d)
+}
+
+// TxWriteFreelist records the time spent writing the freelist.
+func (c *Collector) TxWriteFreelist(tx *bolt.Tx, d time.Duration, n int) {
+	c.phases[PhaseFreelist].Observe(d)
+}
+
+// TxWritePages records the time spent writing dirty pages.
+func (c *Collector) TxWritePages(tx *bolt.Tx, d time.Duration, n int) {
+	c.phases[PhaseWrite].Observe(d)
+}
+
+// TxSync records the time spent in fdatasync().
+func (c *Collector) TxSync(tx *bolt.Tx, d time.Duration) {
+	c.phases[PhaseSync].Observe(d)
+}
+
+// TxWriteMeta records the time spent writing the meta page.
+func (c *Collector) TxWriteMeta(tx *bolt.Tx, d time.Duration) {
+	c.phases[PhaseMeta].Observe(d)
+}
+
+// TxCommit records the total commit latency.
+func (c *Collector) TxCommit(tx *bolt.Tx, d time.Duration, err error) {
+	if err != nil {
+		c.mu.Lock()
+		c.commitErrorN++
+		c.mu.Unlock()
+		return
+	}
+	c.commit.Observe(d)
+}
+
+// Remap records the time spent remapping the data file.
+func (c *Collector) Remap(oldSize, newSize int, d time.Duration) {
+	c.remap.Observe(d)
+}
+
+// Snapshot returns the current metrics for the collector.
+func (c *Collector) Snapshot() Snapshot {
+	c.mu.Lock()
+	db, commitErrorN := c.db, c.commitErrorN
+	c.mu.Unlock()
+
+	s := Snapshot{
+		Labels:       c.labels.clone(),
+		Commit:       c.commit.Snapshot(),
+		CommitErrorN: commitErrorN,
+		Phases:       make(map[string]HistogramSnapshot, len(c.phases)),
+		Remap:        c.remap.Snapshot(),
+	}
+	for phase, h := range c.phases {
+		s.Phases[phase] = h.Snapshot()
+	}
+
+	// Database stats are only available once a database is attached.
+	if db != nil {
+		s.Stats = db.Stats()
+		if fi, err := os.Stat(db.Path()); err == nil {
+			s.FileSize = fi.Size()
+		}
+	}
+
+	return s
+}
+
+// Snapshot represents the metrics of a single database at a point in time.
+type Snapshot struct {
+	Labels   Labels
+	FileSize int64 // size of the data file on disk, in bytes
+	Stats    bolt.Stats
+
+	Commit       HistogramSnapshot            // latency of successful commits
+	CommitErrorN uint64                       // number of failed commits
+	Phases       map[string]HistogramSnapshot // latency of each commit phase
+	Remap        HistogramSnapshot            // latency of data file remaps
+}
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, used for latency histograms.
+// They range from 100µs to 10s.
+var DefaultBuckets = []float64{
+	0.0001, 0.00025, 0.0005,
+	0.001, 0.0025, 0.005,
+	0.01, 0.025, 0.05,
+	0.1, 0.25, 0.5,
+	1, 2.5, 5, 10,
+}
+
+// Histogram counts observed durations in cumulative buckets.
+// It is safe for concurrent use.
+type Histogram struct {
+	mu      sync.Mutex
+	bounds  []float64
+	buckets []uint64 // non-cumulative counts; the last bucket is +Inf
+	count   uint64
+	sum     float64
+}
+
+// NewHistogram returns a histogram with the given upper bounds in seconds.
+// Bounds are sorted before use. If bounds is nil then DefaultBuckets is used.
+func NewHistogram(bounds []float64) *Histogram {
+	if bounds == nil {
+		bounds = DefaultBuckets
+	}
+	b := make([]float64, len(bounds))
+	copy(b, bounds)
+	sort.Float64s(b)
+	return &Histogram{
+		bounds:  b,
+		buckets: make([]uint64, len(b)+1),
+	}
+}
+
+// Observe records a single duration.
+func (h *Histogram) Observe(d time.Duration) {
+	v := d.Seconds()
+	i := sort.SearchFloat64s(h.bounds, v)
+
+	h.mu.Lock()
+	h.buckets[i]++
+	h.count++
+	h.sum += v
+	h.mu.Unlock()
+}
+
+// Snapshot returns a point-in-time copy of the histogram.
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s := HistogramSnapshot{
+		Buckets: make([]Bucket, len(h.bounds)),
+		Count:   h.count,
+		Sum:     h.sum,
+	}
+	var n uint64
+	for i, upper := range h.bounds {
+		n += h.buckets[i]
+		s.Buckets[i] = Bucket{UpperBound: upper, Count: n}
+	}
+	return s
+}
+
+// HistogramSnapshot is a point-in-time copy of a Histogram.
+type HistogramSnapshot struct {
+	Buckets []Bucket // cumulative buckets, excluding +Inf
+	Count   uint64   // total number of observations
+	Sum     float64  // sum of all observations in seconds
+}
+
+// Bucket is a single cumulative histogram bucket.
+type Bucket struct {
+	UpperBound float64 // inclusive upper bound in seconds
+	Count      uint64  // number of observations less than or equal to UpperBound
+}
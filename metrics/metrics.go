@@ -0,0 +1,152 @@
+/*
+Package metrics exports Bolt database and transaction statistics.
+
+A Collector gathers the gauges and counters from DB.Stats() along with commit
+latency histograms for a single database. Collectors are added to a Registry
+which can publish them through expvar and render them in the Prometheus text
+exposition format. Each collector is identified by a set of labels so that
+several databases can be exported from the same process:
+
+	reg := metrics.NewRegistry()
+	reg.Publish("bolt")
+	http.Handle("/metrics", reg)
+
+	c := metrics.NewCollector(metrics.Labels{"db": "users"})
+	db, err := bolt.Open("users.db", 0600, &bolt.Options{Tracer: c})
+	if err != nil {
+		return err
+	}
+	c.Attach(db)
+	if err := reg.Register(c); err != nil {
+		return err
+	}
+*/
+package metrics
+
+import (
+	"errors"
+	"expvar"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrDuplicateLabels is returned when registering a collector whose labels
+// match a collector that is already registered.
+var ErrDuplicateLabels = errors.New("collector with identical labels already registered")
+
+// Labels identify a collector within a registry.
+type Labels map[string]string
+
+// String returns the labels in Prometheus format, sorted by name.
+func (l Labels) String() string {
+	if len(l) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(l))
+	for name := range l {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + `="` + escapeLabelValue(l[name]) + `"`
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// with returns a copy of the labels with an additional label set.
+func (l Labels) with(name, value string) Labels {
+	other := l.clone()
+	other[name] = value
+	return other
+}
+
+// clone returns a copy of the labels.
+func (l Labels) clone() Labels {
+	other := make(Labels, len(l))
+	for k, v := range l {
+		other[k] = v
+	}
+	return other
+}
+
+// escapeLabelValue escapes a label value for the Prometheus text format.
+func escapeLabelValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// Registry holds a set of collectors. It is safe for concurrent use.
+type Registry struct {
+	mu         sync.Mutex
+	collectors []*Collector
+}
+
+// NewRegistry returns a new, empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds a collector to the registry.
+// Returns ErrDuplicateLabels if a collector with the same labels is registered.
+func (r *Registry) Register(c *Collector) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := c.labels.String()
+	for _, other := range r.collectors {
+		if other == c || other.labels.String() == key {
+			return ErrDuplicateLabels
+		}
+	}
+	r.collectors = append(r.collectors, c)
+	return nil
+}
+
+// Unregister removes a collector from the registry.
+func (r *Registry) Unregister(c *Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, other := range r.collectors {
+		if other == c {
+			r.collectors = append(r.collectors[:i], r.collectors[i+1:]...)
+			return
+		}
+	}
+}
+
+// Snapshots returns a snapshot of every registered collector, sorted by labels.
+func (r *Registry) Snapshots() []Snapshot {
+	r.mu.Lock()
+	collectors := make([]*Collector, len(r.collectors))
+	copy(collectors, r.collectors)
+	r.mu.Unlock()
+
+	snapshots := make([]Snapshot, len(collectors))
+	for i, c := range collectors {
+		snapshots[i] = c.Snapshot()
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Labels.String() < snapshots[j].Labels.String()
+	})
+	return snapshots
+}
+
+// Publish exposes the registry through expvar under the given name.
+// Like expvar.Publish, it panics if the name is already in use.
+func (r *Registry) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return r.Snapshots()
+	}))
+}
+
+// ServeHTTP renders the registry in the Prometheus text exposition format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	if err := r.WritePrometheus(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
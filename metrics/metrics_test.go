@@ -0,0 +1,187 @@
+package metrics_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"expvar"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/boltdb/bolt/metrics"
+)
+
+// Ensure that metrics for several databases are rendered with their labels.
+func TestRegistry_WritePrometheus(t *testing.T) {
+	reg := metrics.NewRegistry()
+	for _, name := range []string{"users", "orders"} {
+		c := metrics.NewCollector(metrics.Labels{"db": name})
+		db := MustOpenDB(c)
+		defer db.MustClose()
+		c.Attach(db.DB)
+		if err := reg.Register(c); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := db.Update(func(tx *bolt.Tx) error {
+			_, err := tx.CreateBucket([]byte("widgets"))
+			return err
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := reg.WritePrometheus(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, s := range []string{
+		"# TYPE bolt_mmap_size_bytes gauge\n",
+		`bolt_mmap_size_bytes{db="orders"} 32768` + "\n",
+		`bolt_mmap_size_bytes{db="users"} 32768` + "\n",
+		`bolt_file_size_bytes{db="users"} `,
+		`bolt_read_tx_open{db="users"} 0` + "\n",
+		`bolt_remaps_total{db="users"} 0` + "\n",
+		`bolt_batch_tx_total{db="users"} 0` + "\n",
+		"# TYPE bolt_commit_duration_seconds histogram\n",
+		`bolt_commit_duration_seconds_bucket{db="users",le="+Inf"} 1` + "\n",
+		`bolt_commit_duration_seconds_count{db="orders"} 1` + "\n",
+		`bolt_tx_phase_duration_seconds_count{db="users",phase="sync"} 2` + "\n",
+		`bolt_tx_phase_duration_seconds_count{db="users",phase="lock"} 1` + "\n",
+	} {
+		if !strings.Contains(out, s) {
+			t.Fatalf("expected output to contain %q:\n%s", s, out)
+		}
+	}
+
+	// Headers should only be written once per metric.
+	if n := strings.Count(out, "# TYPE bolt_mmap_size_bytes "); n != 1 {
+		t.Fatalf("unexpected header count: %d", n)
+	}
+}
+
+// Ensure that registering a collector with duplicate labels returns an error.
+func TestRegistry_Register_ErrDuplicateLabels(t *testing.T) {
+	reg := metrics.NewRegistry()
+	if err := reg.Register(metrics.NewCollector(metrics.Labels{"db": "a"})); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.Register(metrics.NewCollector(metrics.Labels{"db": "a"})); err != metrics.ErrDuplicateLabels {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := reg.Register(metrics.NewCollector(metrics.Labels{"db": "b"})); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure that an unregistered collector is no longer rendered.
+func TestRegistry_Unregister(t *testing.T) {
+	reg := metrics.NewRegistry()
+	c := metrics.NewCollector(metrics.Labels{"db": "a"})
+	if err := reg.Register(c); err != nil {
+		t.Fatal(err)
+	}
+	reg.Unregister(c)
+	if n := len(reg.Snapshots()); n != 0 {
+		t.Fatalf("unexpected snapshot count: %d", n)
+	}
+}
+
+// Ensure that the registry can be served over HTTP.
+func TestRegistry_ServeHTTP(t *testing.T) {
+	reg := metrics.NewRegistry()
+	if err := reg.Register(metrics.NewCollector(metrics.Labels{"db": "a"})); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	reg.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); ct != metrics.ContentType {
+		t.Fatalf("unexpected content type: %s", ct)
+	} else if !strings.Contains(w.Body.String(), `bolt_commit_errors_total{db="a"} 0`) {
+		t.Fatalf("unexpected body: %s", w.Body.String())
+	}
+}
+
+// Ensure that the registry can be published through expvar.
+func TestRegistry_Publish(t *testing.T) {
+	reg := metrics.NewRegistry()
+	c := metrics.NewCollector(metrics.Labels{"db": "a"})
+	db := MustOpenDB(c)
+	defer db.MustClose()
+	c.Attach(db.DB)
+	if err := reg.Register(c); err != nil {
+		t.Fatal(err)
+	}
+	reg.Publish("bolt_test")
+
+	var snapshots []metrics.Snapshot
+	if err := json.Unmarshal([]byte(expvar.Get("bolt_test").String()), &snapshots); err != nil {
+		t.Fatal(err)
+	} else if len(snapshots) != 1 {
+		t.Fatalf("unexpected snapshot count: %d", len(snapshots))
+	} else if snapshots[0].Labels["db"] != "a" {
+		t.Fatalf("unexpected labels: %v", snapshots[0].Labels)
+	} else if snapshots[0].Stats.MmapSize == 0 {
+		t.Fatal("expected mmap size")
+	}
+}
+
+// Ensure that histogram buckets are cumulative.
+func TestHistogram_Snapshot(t *testing.T) {
+	h := metrics.NewHistogram([]float64{0.01, 0.001, 0.1})
+	h.Observe(500 * time.Microsecond)
+	h.Observe(5 * time.Millisecond)
+	h.Observe(50 * time.Millisecond)
+	h.Observe(time.Second)
+
+	s := h.Snapshot()
+	if s.Count != 4 {
+		t.Fatalf("unexpected count: %d", s.Count)
+	} else if len(s.Buckets) != 3 {
+		t.Fatalf("unexpected bucket count: %d", len(s.Buckets))
+	}
+	for i, exp := range []metrics.Bucket{{0.001, 1}, {0.01, 2}, {0.1, 3}} {
+		if s.Buckets[i] != exp {
+			t.Fatalf("unexpected bucket(%d): %+v", i, s.Buckets[i])
+		}
+	}
+	if s.Sum < 1.0555 || s.Sum > 1.0556 {
+		t.Fatalf("unexpected sum: %f", s.Sum)
+	}
+}
+
+// DB is a test wrapper for bolt.DB.
+type DB struct {
+	*bolt.DB
+}
+
+// MustOpenDB returns a new, open DB at a temporary location using a tracer.
+func MustOpenDB(tracer bolt.Tracer) *DB {
+	f, err := ioutil.TempFile("", "bolt-metrics-")
+	if err != nil {
+		panic(err)
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	db, err := bolt.Open(f.Name(), 0666, &bolt.Options{Tracer: tracer})
+	if err != nil {
+		panic(err)
+	}
+	return &DB{db}
+}
+
+// MustClose closes the database and deletes the underlying file. Panic on error.
+func (db *DB) MustClose() {
+	defer os.Remove(db.Path())
+	if err := db.Close(); err != nil {
+		panic(err)
+	}
+}
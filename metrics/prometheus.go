@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// ContentType is the content type of the Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// metric describes a single Prometheus metric taken from a snapshot.
+type metric struct {
+	name  string
+	typ   string
+	help  string
+	value func(s *Snapshot) float64
+}
+
+// metrics lists the gauges and counters rendered for each snapshot.
+var metrics = []metric{
+	{"bolt_file_size_bytes", "gauge", "Size of the data file on disk.", func(s *Snapshot) float64 { return float64(s.FileSize) }},
+	{"bolt_mmap_size_bytes", "gauge", "Size of the memory map.", func(s *Snapshot) float64 { return float64(s.Stats.MmapSize) }},
+	{"bolt_remaps_total", "counter", "Number of remaps after the initial mapping.", func(s *Snapshot) float64 { return float64(s.Stats.RemapN) }},
+	{"bolt_remap_seconds_total", "counter", "Time spent remapping, including lock waits.", func(s *Snapshot) float64 { return s.Stats.RemapTime.Seconds() }},
+	{"bolt_freelist_free_pages", "gauge", "Number of free pages on the freelist.", func(s *Snapshot) float64 { return float64(s.Stats.FreePageN) }},
+	{"bolt_freelist_pending_pages", "gauge", "Number of pending pages on the freelist.", func(s *Snapshot) float64 { return float64(s.Stats.PendingPageN) }},
+	{"bolt_freelist_alloc_bytes", "gauge", "Bytes allocated in free pages.", func(s *Snapshot) float64 { return float64(s.Stats.FreeAlloc) }},
+	{"bolt_freelist_inuse_bytes", "gauge", "Bytes used by the freelist.", func(s *Snapshot) float64 { return float64(s.Stats.FreelistInuse) }},
+	{"bolt_read_tx_total", "counter", "Number of started read transactions.", func(s *Snapshot) float64 { return float64(s.Stats.TxN) }},
+	{"bolt_read_tx_open", "gauge", "Number of currently open read transactions.", func(s *Snapshot) float64 { return float64(s.Stats.OpenTxN) }},
+	{"bolt_batch_tx_total", "counter", "Number of batch transactions committed.", func(s *Snapshot) float64 { return float64(s.Stats.BatchN) }},
+	{"bolt_batch_calls_total", "counter", "Number of calls committed in batch transactions.", func(s *Snapshot) float64 { return float64(s.Stats.BatchCallN) }},
+	{"bolt_batch_solo_total", "counter", "Number of batch calls retried in their own transaction.", func(s *Snapshot) float64 { return float64(s.Stats.BatchSoloN) }},
+	{"bolt_batch_splits_total", "counter", "Number of batches split by the size limit.", func(s *Snapshot) float64 { return float64(s.Stats.BatchSplitN) }},
+	{"bolt_batch_wait_seconds_total", "counter", "Time batch calls waited for their batch to start.", func(s *Snapshot) float64 { return s.Stats.BatchWait.Seconds() }},
+	{"bolt_tx_page_allocations_total", "counter", "Number of page allocations.", func(s *Snapshot) float64 { return float64(s.Stats.TxStats.PageCount) }},
+	{"bolt_tx_page_alloc_bytes_total", "counter", "Bytes allocated for pages.", func(s *Snapshot) float64 { return float64(s.Stats.TxStats.PageAlloc) }},
+	{"bolt_tx_cursors_total", "counter", "Number of cursors created.", func(s *Snapshot) float64 { return float64(s.Stats.TxStats.CursorCount) }},
+	{"bolt_tx_nodes_total", "counter", "Number of node allocations.", func(s *Snapshot) float64 { return float64(s.Stats.TxStats.NodeCount) }},
+	{"bolt_tx_node_derefs_total", "counter", "Number of node dereferences.", func(s *Snapshot) float64 { return float64(s.Stats.TxStats.NodeDeref) }},
+	{"bolt_tx_rebalances_total", "counter", "Number of node rebalances.", func(s *Snapshot) float64 { return float64(s.Stats.TxStats.Rebalance) }},
+	{"bolt_tx_rebalance_seconds_total", "counter", "Time spent rebalancing.", func(s *Snapshot) float64 { return s.Stats.TxStats.RebalanceTime.Seconds() }},
+	{"bolt_tx_splits_total", "counter", "Number of nodes split.", func(s *Snapshot) float64 { return float64(s.Stats.TxStats.Split) }},
+	{"bolt_tx_spills_total", "counter", "Number of nodes spilled.", func(s *Snapshot) float64 { return float64(s.Stats.TxStats.Spill) }},
+	{"bolt_tx_spill_seconds_total", "counter", "Time spent spilling.", func(s *Snapshot) float64 { return s.Stats.TxStats.SpillTime.Seconds() }},
+	{"bolt_tx_writes_total", "counter", "Number of writes performed.", func(s *Snapshot) float64 { return float64(s.Stats.TxStats.Write) }},
+	{"bolt_tx_write_seconds_total", "counter", "Time spent writing to disk.", func(s *Snapshot) float64 { return s.Stats.TxStats.WriteTime.Seconds() }},
+	{"bolt_commit_errors_total", "counter", "Number of failed commits.", func(s *Snapshot) float64 { return float64(s.CommitErrorN) }},
+}
+
+// WritePrometheus writes the metrics of every registered collector to w
+// in the Prometheus text exposition format.
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	snapshots := r.Snapshots()
+	bw := bufio.NewWriter(w)
+
+	// Write gauges and counters.
+	for _, m := range metrics {
+		writeHeader(bw, m.name, m.typ, m.help)
+		for i := range snapshots {
+			s := &snapshots[i]
+			writeSample(bw, m.name, s.Labels, m.value(s))
+		}
+	}
+
+	// Write commit latency histograms.
+	writeHeader(bw, "bolt_commit_duration_seconds", "histogram", "Latency of successful commits.")
+	for i := range snapshots {
+		writeHistogram(bw, "bolt_commit_duration_seconds", snapshots[i].Labels, snapshots[i].Commit)
+	}
+
+	// Write per-phase latency histograms.
+	writeHeader(bw, "bolt_tx_phase_duration_seconds", "histogram", "Latency of each phase of a write transaction.")
+	for i := range snapshots {
+		for _, phase := range phases {
+			labels := snapshots[i].Labels.with("phase", phase)
+			writeHistogram(bw, "bolt_tx_phase_duration_seconds", labels, snapshots[i].Phases[phase])
+		}
+	}
+
+	// Write remap latency histograms.
+	writeHeader(bw, "bolt_remap_duration_seconds", "histogram", "Latency of data file remaps.")
+	for i := range snapshots {
+		writeHistogram(bw, "bolt_remap_duration_seconds", snapshots[i].Labels, snapshots[i].Remap)
+	}
+
+	return bw.Flush()
+}
+
+func writeHeader(w *bufio.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, typ)
+}
+
+func writeSample(w *bufio.Writer, name string, labels Labels, v float64) {
+	fmt.Fprintf(w, "%s%s %s\n", name, labels, formatFloat(v))
+}
+
+func writeHistogram(w *bufio.Writer, name string, labels Labels, h HistogramSnapshot) {
+	for _, b := range h.Buckets {
+		writeSample(w, name+"_bucket", labels.with("le", formatFloat(b.UpperBound)), float64(b.Count))
+	}
+	writeSample(w, name+"_bucket", labels.with("le", "+Inf"), float64(h.Count))
+	writeSample(w, name+"_sum", labels, h.Sum)
+	writeSample(w, name+"_count", labels, float64(h.Count))
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
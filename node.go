@@ -18,6 +18,16 @@ type node struct {
 	parent     *node
 	children   nodes
 	inodes     inodes
+	prefix     prefixCache
+}
+
+// prefixCache holds the result of prefixLen along with the first and last
+// keys it was computed from. Put and del, as well as splits and merges,
+// replace the first or last key whenever they can change the prefix, which
+// invalidates the cache.
+type prefixCache struct {
+	first, last []byte
+	n           int
 }
 
 // root returns the top-level node this node is attached to.
@@ -38,10 +48,10 @@ func (n *node) minKeys() int {
 
 // size returns the size of the node after serialization.
 func (n *node) size() int {
-	sz, elsz := pageHeaderSize, n.pageElementSize()
+	sz, elsz, plen := n.headerSize(), n.pageElementSize(), n.prefixLen()
 	for i := 0; i < len(n.inodes); i++ {
 		item := &n.inodes[i]
-		sz += elsz + len(item.key) + len(item.value)
+		sz += elsz + len(item.key) - plen + len(item.value)
 	}
 	return sz
 }
@@ -50,10 +60,10 @@ func (n *node) size() int {
 // This is an optimization to avoid calculating a large node when we only need
 // to know if it fits inside a certain page size.
 func (n *node) sizeLessThan(v int) bool {
-	sz, elsz := pageHeaderSize, n.pageElementSize()
+	sz, elsz, plen := n.headerSize(), n.pageElementSize(), n.prefixLen()
 	for i := 0; i < len(n.inodes); i++ {
 		item := &n.inodes[i]
-		sz += elsz + len(item.key) + len(item.value)
+		sz += elsz + len(item.key) - plen + len(item.value)
 		if sz >= v {
 			return false
 		}
@@ -61,6 +71,42 @@ func (n *node) sizeLessThan(v int) bool {
 	return true
 }
 
+// headerSize returns the size of the page header plus the shared key prefix,
+// if the node is written with prefix compression.
+func (n *node) headerSize() int {
+	if plen := n.prefixLen(); plen > 0 {
+		return pageHeaderSize + prefixHeaderSize + plen
+	}
+	return pageHeaderSize
+}
+
+// prefixLen returns the length of the key prefix shared by every inode when
+// the database has prefix compression enabled. Returns zero for branch nodes
+// and for leaves with fewer than two keys.
+func (n *node) prefixLen() int {
+	if !n.isLeaf || len(n.inodes) < 2 || (n.bucket.tx.meta.flags&prefixCompressionFlag) == 0 {
+		return 0
+	}
+
+	// Keys are sorted so the first and last keys share the shortest prefix.
+	first, last := n.inodes[0].key, n.inodes[len(n.inodes)-1].key
+	if c := &n.prefix; c.first != nil && sameSlice(c.first, first) && sameSlice(c.last, last) {
+		return c.n
+	}
+
+	var i int
+	for i < len(first) && i < len(last) && first[i] == last[i] {
+		i++
+	}
+	n.prefix = prefixCache{first: first, last: last, n: i}
+	return i
+}
+
+// sameSlice returns true if a and b refer to the same bytes in memory.
+func sameSlice(a, b []byte) bool {
+	return len(a) == len(b) && len(a) > 0 && &a[0] == &b[0]
+}
+
 // pageElementSize returns the size of each page element based on the type of node.
 func (n *node) pageElementSize() int {
 	if n.isLeaf {
@@ -163,12 +209,13 @@ func (n *node) read(p *page) {
 	n.isLeaf = ((p.flags & leafPageFlag) != 0)
 	n.inodes = make(inodes, int(p.count))
 
+	var keys keyBuffer
 	for i := 0; i < int(p.count); i++ {
 		inode := &n.inodes[i]
 		if n.isLeaf {
 			elem := p.leafPageElement(uint16(i))
 			inode.flags = elem.flags
-			inode.key = elem.key()
+			inode.key = p.leafKey(uint16(i), &keys)
 			inode.value = elem.value()
 		} else {
 			elem := p.branchPageElement(uint16(i))
@@ -208,20 +255,31 @@ func (n *node) write(p *page) {
 
 	// Loop over each item and write it to the page.
 	b := (*[maxAllocSize]byte)(unsafe.Pointer(&p.ptr))[n.pageElementSize()*len(n.inodes):]
+
+	// Write the shared key prefix before the data and strip it from each key.
+	plen := n.prefixLen()
+	if plen > 0 {
+		p.flags |= prefixPageFlag
+		*(*uint16)(unsafe.Pointer(&b[0])) = uint16(plen)
+		copy(b[prefixHeaderSize:], n.inodes[0].key[:plen])
+		b = b[prefixHeaderSize+plen:]
+	}
+
 	for i, item := range n.inodes {
 		_assert(len(item.key) > 0, "write: zero-length inode key")
+		key := item.key[plen:]
 
 		// Write the page element.
 		if n.isLeaf {
 			elem := p.leafPageElement(uint16(i))
 			elem.pos = uint32(uintptr(unsafe.Pointer(&b[0])) - uintptr(unsafe.Pointer(elem)))
 			elem.flags = item.flags
-			elem.ksize = uint32(len(item.key))
+			elem.ksize = uint32(len(key))
 			elem.vsize = uint32(len(item.value))
 		} else {
 			elem := p.branchPageElement(uint16(i))
 			elem.pos = uint32(uintptr(unsafe.Pointer(&b[0])) - uintptr(unsafe.Pointer(elem)))
-			elem.ksize = uint32(len(item.key))
+			elem.ksize = uint32(len(key))
 			elem.pgid = item.pgid
 			_assert(elem.pgid != p.id, "write: circular dependency occurred")
 		}
@@ -230,13 +288,13 @@ func (n *node) write(p *page) {
 		// then we need to reallocate the byte array pointer.
 		//
 		// See: https://github.com/boltdb/bolt/pull/335
-		klen, vlen := len(item.key), len(item.value)
+		klen, vlen := len(key), len(item.value)
 		if len(b) < klen+vlen {
 			b = (*[maxAllocSize]byte)(unsafe.Pointer(&b[0]))[:]
 		}
 
 		// Write data for the element to the end of the page.
-		copy(b[0:], item.key)
+		copy(b[0:], key)
 		b = b[klen:]
 		copy(b[0:], item.value)
 		b = b[vlen:]
@@ -313,13 +371,16 @@ func (n *node) splitTwo(pageSize int) (*node, *node) {
 // It returns the index as well as the size of the first page.
 // This is only be called from split().
 func (n *node) splitIndex(threshold int) (index, sz int) {
-	sz = pageHeaderSize
+	// Both halves share at least the prefix of the whole node so sizes
+	// computed with it are an upper bound for prefix compressed pages.
+	sz = n.headerSize()
+	plen := n.prefixLen()
 
 	// Loop until we only have the minimum number of keys required for the second page.
 	for i := 0; i < len(n.inodes)-minKeysPerPage; i++ {
 		index = i
 		inode := n.inodes[i]
-		elsize := n.pageElementSize() + len(inode.key) + len(inode.value)
+		elsize := n.pageElementSize() + len(inode.key) - plen + len(inode.value)
 
 		// If we have at least the minimum number of keys and adding another
 		// node would put us over the threshold then exit and return.
@@ -558,30 +619,32 @@ func (n *node) free() {
 	}
 }
 
-// dump writes the contents of the node to STDERR for debugging purposes.
+// dump writes the contents of the node to the DB logger for debugging purposes.
 /*
 func (n *node) dump() {
+	var logger = n.bucket.tx.db.logger
+
 	// Write node header.
 	var typ = "branch"
 	if n.isLeaf {
 		typ = "leaf"
 	}
-	warnf("[NODE %d {type=%s count=%d}]", n.pgid, typ, len(n.inodes))
+	logger.Debugf("[NODE %d {type=%s count=%d}]", n.pgid, typ, len(n.inodes))
 
 	// Write out abbreviated version of each item.
 	for _, item := range n.inodes {
 		if n.isLeaf {
 			if item.flags&bucketLeafFlag != 0 {
 				bucket := (*bucket)(unsafe.Pointer(&item.value[0]))
-				warnf("+L %08x -> (bucket root=%d)", trunc(item.key, 4), bucket.root)
+				logger.Debugf("+L %08x -> (bucket root=%d)", trunc(item.key, 4), bucket.root)
 			} else {
-				warnf("+L %08x -> %08x", trunc(item.key, 4), trunc(item.value, 4))
+				logger.Debugf("+L %08x -> %08x", trunc(item.key, 4), trunc(item.value, 4))
 			}
 		} else {
-			warnf("+B %08x -> pgid=%d", trunc(item.key, 4), item.pgid)
+			logger.Debugf("+B %08x -> pgid=%d", trunc(item.key, 4), item.pgid)
 		}
 	}
-	warn("")
+	logger.Debugf("")
 }
 */
 
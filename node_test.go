@@ -154,3 +154,29 @@ func TestNode_split_SinglePage(t *testing.T) {
 		t.Fatalf("expected nil parent")
 	}
 }
+
+// Ensure that the cached prefix length follows changes to the first and last keys.
+func TestNode_prefixLen(t *testing.T) {
+	n := &node{isLeaf: true, inodes: make(inodes, 0), bucket: &Bucket{tx: &Tx{meta: &meta{pgid: 1, flags: prefixCompressionFlag}}}}
+	n.put([]byte("abc1"), []byte("abc1"), []byte("1"), 0, 0)
+	n.put([]byte("abc2"), []byte("abc2"), []byte("2"), 0, 0)
+	if plen := n.prefixLen(); plen != 3 {
+		t.Fatalf("exp=3; got=%d", plen)
+	}
+
+	for _, tt := range []struct {
+		fn  func()
+		exp int
+	}{
+		{func() { n.put([]byte("abd"), []byte("abd"), nil, 0, 0) }, 2},
+		{func() { n.put([]byte("abc0"), []byte("abc0"), nil, 0, 0) }, 2},
+		{func() { n.del([]byte("abd")) }, 3},
+		{func() { n.put([]byte("b"), []byte("b"), nil, 0, 0) }, 0},
+		{func() { n.inodes = n.inodes[:len(n.inodes)-1] }, 3},
+	} {
+		tt.fn()
+		if plen := n.prefixLen(); plen != tt.exp {
+			t.Fatalf("exp=%d; got=%d", tt.exp, plen)
+		}
+	}
+}
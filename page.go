@@ -14,11 +14,16 @@ const minKeysPerPage = 2
 const branchPageElementSize = int(unsafe.Sizeof(branchPageElement{}))
 const leafPageElementSize = int(unsafe.Sizeof(leafPageElement{}))
 
+// prefixHeaderSize is the size of the shared key prefix length stored on
+// prefix compressed leaf pages.
+const prefixHeaderSize = 2
+
 const (
 	branchPageFlag   = 0x01
 	leafPageFlag     = 0x02
 	metaPageFlag     = 0x04
 	freelistPageFlag = 0x10
+	prefixPageFlag   = 0x20
 )
 
 const (
@@ -60,6 +65,60 @@ func (p *page) leafPageElement(index uint16) *leafPageElement {
 	return n
 }
 
+// keyPrefix returns the prefix shared by every key on a leaf page written
+// with prefix compression. The prefix is stored after the element headers
+// as a 2-byte length followed by its bytes. Returns nil for other pages.
+func (p *page) keyPrefix() []byte {
+	if (p.flags & prefixPageFlag) == 0 {
+		return nil
+	}
+	ptr := unsafe.Pointer(uintptr(unsafe.Pointer(&p.ptr)) + uintptr(leafPageElementSize*int(p.count)))
+	n := int(*(*uint16)(ptr))
+	return (*[maxAllocSize]byte)(unsafe.Pointer(uintptr(ptr) + prefixHeaderSize))[:n:n]
+}
+
+// leafKey returns the full key of the leaf element at index. Keys on prefix
+// compressed pages are joined with their prefix in buf.
+func (p *page) leafKey(index uint16, buf *keyBuffer) []byte {
+	key := p.leafPageElement(index).key()
+	if prefix := p.keyPrefix(); prefix != nil {
+		key = buf.join(prefix, key)
+	}
+	return key
+}
+
+// maxKeyBufferSize is the largest allocation made by a keyBuffer for keys
+// that fit in it.
+const maxKeyBufferSize = 4096
+
+// keyBuffer joins the prefixes and suffixes of keys on prefix compressed
+// pages. Keys are carved out of larger allocations, which double in size up
+// to maxKeyBufferSize, so that iterating over a page doesn't allocate for
+// every key. The memory is never reused since keys must remain valid for the
+// life of the transaction.
+type keyBuffer struct {
+	buf []byte
+}
+
+// join returns prefix followed by suffix.
+func (b *keyBuffer) join(prefix, suffix []byte) []byte {
+	n := len(prefix) + len(suffix)
+	if cap(b.buf)-len(b.buf) < n {
+		sz := 2 * cap(b.buf)
+		if sz > maxKeyBufferSize {
+			sz = maxKeyBufferSize
+		}
+		if sz < n {
+			sz = n
+		}
+		b.buf = make([]byte, 0, sz)
+	}
+
+	i := len(b.buf)
+	b.buf = append(append(b.buf, prefix...), suffix...)
+	return b.buf[i:len(b.buf):len(b.buf)]
+}
+
 // leafPageElements retrieves a list of leaf nodes.
 func (p *page) leafPageElements() []leafPageElement {
 	if p.count == 0 {
@@ -0,0 +1,313 @@
+package bolt
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+)
+
+// Router returns the index of the shard that owns a key given n shards.
+// The returned index must be in the range [0, n).
+type Router func(key []byte, n int) int
+
+// HashRouter routes keys to shards by their 32-bit FNV-1a hash.
+// It is the default router used by OpenSharded.
+func HashRouter(key []byte, n int) int {
+	h := fnv.New32a()
+	_, _ = h.Write(key)
+	return int(h.Sum32() % uint32(n))
+}
+
+// RangeRouter returns a router that splits the key space into contiguous
+// ranges. Keys less than bounds[0] are routed to shard 0, keys less than
+// bounds[1] to shard 1 and so on. Keys greater than or equal to every bound
+// are routed to shard len(bounds). The bounds are sorted before use and
+// len(bounds) must be one less than the number of shards.
+func RangeRouter(bounds ...[]byte) Router {
+	b := make([][]byte, len(bounds))
+	copy(b, bounds)
+	sort.Slice(b, func(i, j int) bool { return bytes.Compare(b[i], b[j]) < 0 })
+
+	return func(key []byte, n int) int {
+		return sort.Search(len(b), func(i int) bool { return bytes.Compare(key, b[i]) < 0 })
+	}
+}
+
+// ShardedDB spreads keys across several databases so that writes to
+// different shards can proceed concurrently.
+//
+// Each shard is a regular DB and keys are assigned to shards by a Router.
+// Write transactions are always local to a single shard. Read transactions
+// can span every shard but each shard is read from its own snapshot; there
+// is no atomicity across shards.
+type ShardedDB struct {
+	shards []*DB
+	router Router
+}
+
+// OpenSharded opens a database for each path and returns a ShardedDB over
+// them. The order of paths determines the shard indexes so it must remain
+// the same between calls. If router is nil then HashRouter is used. The mode
+// and options are passed to Open for each shard.
+func OpenSharded(paths []string, mode os.FileMode, router Router, options *Options) (*ShardedDB, error) {
+	if len(paths) == 0 {
+		return nil, ErrShardsRequired
+	}
+	if router == nil {
+		router = HashRouter
+	}
+
+	sdb := &ShardedDB{router: router}
+	for _, path := range paths {
+		db, err := Open(path, mode, options)
+		if err != nil {
+			_ = sdb.Close()
+			return nil, err
+		}
+		sdb.shards = append(sdb.shards, db)
+	}
+	return sdb, nil
+}
+
+// Close closes every shard and returns the first error encountered.
+func (sdb *ShardedDB) Close() error {
+	var err error
+	for _, db := range sdb.shards {
+		if e := db.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Shards returns the underlying databases in shard order.
+func (sdb *ShardedDB) Shards() []*DB {
+	shards := make([]*DB, len(sdb.shards))
+	copy(shards, sdb.shards)
+	return shards
+}
+
+// Shard returns the index of the shard that owns key.
+func (sdb *ShardedDB) Shard(key []byte) int {
+	i := sdb.router(key, len(sdb.shards))
+	if i < 0 || i >= len(sdb.shards) {
+		panic(fmt.Sprintf("router returned shard %d of %d", i, len(sdb.shards)))
+	}
+	return i
+}
+
+// Update executes a function within a read-write transaction on the shard
+// that owns key. See DB.Update for details.
+func (sdb *ShardedDB) Update(key []byte, fn func(*Tx) error) error {
+	return sdb.shards[sdb.Shard(key)].Update(fn)
+}
+
+// Batch calls fn as part of a batch on the shard that owns key.
+// See DB.Batch for details.
+func (sdb *ShardedDB) Batch(key []byte, fn func(*Tx) error) error {
+	return sdb.shards[sdb.Shard(key)].Batch(fn)
+}
+
+// View executes a function within a read-only transaction on every shard.
+// Any error that is returned from the function is returned from View.
+//
+// Transactions are started on each shard in order. Each one sees a
+// consistent snapshot of its shard but writes committed to other shards while
+// the transactions are being started may or may not be visible.
+func (sdb *ShardedDB) View(fn func(*ShardedTx) error) error {
+	stx := &ShardedTx{sdb: sdb}
+
+	// Make sure every transaction is rolled back, even in the event of a panic.
+	defer func() {
+		for _, t := range stx.txs {
+			t.managed = false
+			_ = t.Rollback()
+		}
+	}()
+
+	for _, db := range sdb.shards {
+		t, err := db.Begin(false)
+		if err != nil {
+			return err
+		}
+
+		// Mark as a managed tx so that the inner function cannot manually rollback.
+		t.managed = true
+		stx.txs = append(stx.txs, t)
+	}
+
+	return fn(stx)
+}
+
+// ShardedTx represents a set of read-only transactions, one per shard.
+type ShardedTx struct {
+	sdb *ShardedDB
+	txs []*Tx
+}
+
+// Tx returns the transaction for the shard at index i.
+func (stx *ShardedTx) Tx(i int) *Tx {
+	return stx.txs[i]
+}
+
+// Get retrieves the value for a key in the named top-level bucket from the
+// shard that owns the key. Returns nil if the bucket or key does not exist.
+func (stx *ShardedTx) Get(name, key []byte) []byte {
+	b := stx.txs[stx.sdb.Shard(key)].Bucket(name)
+	if b == nil {
+		return nil
+	}
+	return b.Get(key)
+}
+
+// Cursor returns a cursor that merges the named top-level bucket across all
+// shards in key order. Shards which do not have the bucket are skipped.
+func (stx *ShardedTx) Cursor(name []byte) *MergedCursor {
+	var cursors []*Cursor
+	for _, t := range stx.txs {
+		if b := t.Bucket(name); b != nil {
+			cursors = append(cursors, b.Cursor())
+		}
+	}
+	return NewMergedCursor(cursors...)
+}
+
+// MergedCursor iterates over several cursors as if they were a single cursor.
+// Keys are returned in sorted order. If the same key exists in more than one
+// cursor then they are returned in the order the cursors were passed in.
+//
+// The underlying cursors must not be used directly while the merged cursor
+// is in use.
+type MergedCursor struct {
+	cursors []*Cursor
+	keys    [][]byte
+	values  [][]byte
+	index   int  // index of the current cursor or -1 if unpositioned
+	reverse bool // true if the cursors were last moved backward
+}
+
+// NewMergedCursor returns a cursor that merges the given cursors.
+func NewMergedCursor(cursors ...*Cursor) *MergedCursor {
+	return &MergedCursor{
+		cursors: cursors,
+		keys:    make([][]byte, len(cursors)),
+		values:  make([][]byte, len(cursors)),
+		index:   -1,
+	}
+}
+
+// First moves the cursor to the first item across all cursors and returns
+// its key and value. If every cursor is empty then a nil key and value are
+// returned.
+func (c *MergedCursor) First() (key []byte, value []byte) {
+	for i, cur := range c.cursors {
+		c.keys[i], c.values[i] = cur.First()
+	}
+	c.reverse = false
+	return c.pick()
+}
+
+// Last moves the cursor to the last item across all cursors and returns its
+// key and value. If every cursor is empty then a nil key and value are
+// returned.
+func (c *MergedCursor) Last() (key []byte, value []byte) {
+	for i, cur := range c.cursors {
+		c.keys[i], c.values[i] = cur.Last()
+	}
+	c.reverse = true
+	return c.pick()
+}
+
+// Seek moves the cursor to the first key greater than or equal to seek and
+// returns its key and value. If no such key exists then a nil key and value
+// are returned.
+func (c *MergedCursor) Seek(seek []byte) (key []byte, value []byte) {
+	for i, cur := range c.cursors {
+		c.keys[i], c.values[i] = cur.Seek(seek)
+	}
+	c.reverse = false
+	return c.pick()
+}
+
+// Next moves the cursor to the next item and returns its key and value.
+// If the cursor is at the end then a nil key and value are returned.
+func (c *MergedCursor) Next() (key []byte, value []byte) {
+	if c.index == -1 {
+		return nil, nil
+	}
+
+	// If we were moving backward then every other cursor is positioned
+	// before the current key. Move them to the first item after it.
+	if c.reverse {
+		k := c.keys[c.index]
+		for i, cur := range c.cursors {
+			if i == c.index {
+				continue
+			}
+			c.keys[i], c.values[i] = cur.Seek(k)
+			if i < c.index && c.keys[i] != nil && bytes.Equal(c.keys[i], k) {
+				c.keys[i], c.values[i] = cur.Next()
+			}
+		}
+		c.reverse = false
+	}
+
+	c.keys[c.index], c.values[c.index] = c.cursors[c.index].Next()
+	return c.pick()
+}
+
+// Prev moves the cursor to the previous item and returns its key and value.
+// If the cursor is at the beginning then a nil key and value are returned.
+func (c *MergedCursor) Prev() (key []byte, value []byte) {
+	if c.index == -1 {
+		return nil, nil
+	}
+
+	// If we were moving forward then every other cursor is positioned
+	// after the current key. Move them to the last item before it.
+	if !c.reverse {
+		k := c.keys[c.index]
+		for i, cur := range c.cursors {
+			if i == c.index {
+				continue
+			}
+			c.keys[i], c.values[i] = cur.Seek(k)
+			if c.keys[i] == nil {
+				c.keys[i], c.values[i] = cur.Last()
+			} else if cmp := bytes.Compare(c.keys[i], k); cmp > 0 || (cmp == 0 && i > c.index) {
+				c.keys[i], c.values[i] = cur.Prev()
+			}
+		}
+		c.reverse = true
+	}
+
+	c.keys[c.index], c.values[c.index] = c.cursors[c.index].Prev()
+	return c.pick()
+}
+
+// pick selects the smallest current key when moving forward or the largest
+// when moving backward and returns its key and value.
+func (c *MergedCursor) pick() ([]byte, []byte) {
+	c.index = -1
+	for i, k := range c.keys {
+		if k == nil {
+			continue
+		}
+		if c.index == -1 {
+			c.index = i
+			continue
+		}
+
+		cmp := bytes.Compare(k, c.keys[c.index])
+		if (!c.reverse && cmp < 0) || (c.reverse && cmp >= 0) {
+			c.index = i
+		}
+	}
+
+	if c.index == -1 {
+		return nil, nil
+	}
+	return c.keys[c.index], c.values[c.index]
+}
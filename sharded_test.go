@@ -0,0 +1,217 @@
+package bolt_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+// Ensure that keys are routed to shards and can be iterated in key order.
+func TestShardedDB_Cursor(t *testing.T) {
+	sdb := MustOpenShardedDB(4, nil)
+	defer sdb.MustClose()
+
+	for i := 0; i < 200; i++ {
+		key := []byte(fmt.Sprintf("%04d", i))
+		if err := sdb.Update(key, func(tx *bolt.Tx) error {
+			b, err := tx.CreateBucketIfNotExists([]byte("widgets"))
+			if err != nil {
+				return err
+			}
+			return b.Put(key, []byte(fmt.Sprintf("value-%d", i)))
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Every shard should have received some keys.
+	for i, db := range sdb.Shards() {
+		if err := db.View(func(tx *bolt.Tx) error {
+			if n := tx.Bucket([]byte("widgets")).Stats().KeyN; n == 0 {
+				t.Fatalf("shard %d: expected keys", i)
+			}
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := sdb.View(func(tx *bolt.ShardedTx) error {
+		if v := tx.Get([]byte("widgets"), []byte("0123")); string(v) != "value-123" {
+			t.Fatalf("unexpected value: %q", v)
+		} else if v := tx.Get([]byte("no_such_bucket"), []byte("0123")); v != nil {
+			t.Fatalf("unexpected value: %q", v)
+		}
+
+		// Iterate forward.
+		c := tx.Cursor([]byte("widgets"))
+		var n int
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if exp := fmt.Sprintf("%04d", n); string(k) != exp {
+				t.Fatalf("unexpected key(%d): %q", n, k)
+			} else if string(v) != fmt.Sprintf("value-%d", n) {
+				t.Fatalf("unexpected value(%d): %q", n, v)
+			}
+			n++
+		}
+		if n != 200 {
+			t.Fatalf("unexpected count: %d", n)
+		}
+
+		// Iterate backward.
+		for k, _ := c.Last(); k != nil; k, _ = c.Prev() {
+			n--
+			if exp := fmt.Sprintf("%04d", n); string(k) != exp {
+				t.Fatalf("unexpected key(%d): %q", n, k)
+			}
+		}
+		if n != 0 {
+			t.Fatalf("unexpected count: %d", n)
+		}
+
+		// Seek and change direction.
+		if k, _ := c.Seek([]byte("0100")); string(k) != "0100" {
+			t.Fatalf("unexpected key: %q", k)
+		} else if k, _ := c.Prev(); string(k) != "0099" {
+			t.Fatalf("unexpected key: %q", k)
+		} else if k, _ := c.Prev(); string(k) != "0098" {
+			t.Fatalf("unexpected key: %q", k)
+		} else if k, _ := c.Next(); string(k) != "0099" {
+			t.Fatalf("unexpected key: %q", k)
+		} else if k, _ := c.Next(); string(k) != "0100" {
+			t.Fatalf("unexpected key: %q", k)
+		} else if k, _ := c.Seek([]byte("1000")); k != nil {
+			t.Fatalf("unexpected key: %q", k)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure that a range router assigns contiguous key ranges to shards.
+func TestShardedDB_RangeRouter(t *testing.T) {
+	sdb := MustOpenShardedDB(3, bolt.RangeRouter([]byte("n"), []byte("g")))
+	defer sdb.MustClose()
+
+	for _, tt := range []struct {
+		key   string
+		shard int
+	}{
+		{"apple", 0},
+		{"g", 1},
+		{"kiwi", 1},
+		{"n", 2},
+		{"zucchini", 2},
+	} {
+		if i := sdb.Shard([]byte(tt.key)); i != tt.shard {
+			t.Fatalf("%s: unexpected shard: %d", tt.key, i)
+		}
+	}
+}
+
+// Ensure that duplicate keys across cursors are returned in cursor order.
+func TestMergedCursor_Duplicates(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		for i, keys := range [][]string{{"a", "c"}, {"b", "c"}, {"c", "d"}} {
+			b, err := tx.CreateBucket([]byte{byte(i)})
+			if err != nil {
+				return err
+			}
+			for _, k := range keys {
+				if err := b.Put([]byte(k), []byte{byte(i)}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.View(func(tx *bolt.Tx) error {
+		c := bolt.NewMergedCursor(
+			tx.Bucket([]byte{0}).Cursor(),
+			tx.Bucket([]byte{1}).Cursor(),
+			tx.Bucket([]byte{2}).Cursor(),
+		)
+
+		var fwd []string
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			fwd = append(fwd, fmt.Sprintf("%s%d", k, v[0]))
+		}
+		if s := fmt.Sprint(fwd); s != "[a0 b1 c0 c1 c2 d2]" {
+			t.Fatalf("unexpected forward order: %s", s)
+		}
+
+		var rev []string
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			rev = append(rev, fmt.Sprintf("%s%d", k, v[0]))
+		}
+		if s := fmt.Sprint(rev); s != "[d2 c2 c1 c0 b1 a0]" {
+			t.Fatalf("unexpected reverse order: %s", s)
+		}
+
+		// Change direction in the middle of duplicate keys.
+		c.First()
+		c.Next()
+		if k, v := c.Next(); !bytes.Equal(k, []byte("c")) || v[0] != 0 {
+			t.Fatalf("unexpected key/value: %s/%d", k, v[0])
+		} else if k, v := c.Next(); !bytes.Equal(k, []byte("c")) || v[0] != 1 {
+			t.Fatalf("unexpected key/value: %s/%d", k, v[0])
+		} else if k, v := c.Prev(); !bytes.Equal(k, []byte("c")) || v[0] != 0 {
+			t.Fatalf("unexpected key/value: %s/%d", k, v[0])
+		} else if k, v := c.Next(); !bytes.Equal(k, []byte("c")) || v[0] != 1 {
+			t.Fatalf("unexpected key/value: %s/%d", k, v[0])
+		} else if k, v := c.Next(); !bytes.Equal(k, []byte("c")) || v[0] != 2 {
+			t.Fatalf("unexpected key/value: %s/%d", k, v[0])
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure that opening a sharded database without paths returns an error.
+func TestOpenSharded_ErrShardsRequired(t *testing.T) {
+	if _, err := bolt.OpenSharded(nil, 0666, nil, nil); err != bolt.ErrShardsRequired {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// ShardedDB is a test wrapper for bolt.ShardedDB.
+type ShardedDB struct {
+	*bolt.ShardedDB
+	paths []string
+}
+
+// MustOpenShardedDB returns a new, open sharded DB with n shards at temporary locations.
+func MustOpenShardedDB(n int, router bolt.Router) *ShardedDB {
+	paths := make([]string, n)
+	for i := range paths {
+		paths[i] = tempfile()
+	}
+	sdb, err := bolt.OpenSharded(paths, 0666, router, nil)
+	if err != nil {
+		panic(err)
+	}
+	return &ShardedDB{ShardedDB: sdb, paths: paths}
+}
+
+// MustClose closes every shard and deletes the underlying files. Panic on error.
+func (sdb *ShardedDB) MustClose() {
+	defer func() {
+		for _, path := range sdb.paths {
+			os.Remove(path)
+		}
+	}()
+	if err := sdb.Close(); err != nil {
+		panic(err)
+	}
+}
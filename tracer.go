@@ -0,0 +1,68 @@
+package bolt
+
+import "time"
+
+// Tracer receives callbacks as transactions move through their lifecycle.
+// It can be used to emit tracing spans for individual transactions, which
+// the aggregated TxStats cannot provide.
+//
+// The *Tx passed to a callback is only valid for the duration of that
+// callback. Read-only transactions begin concurrently so implementations
+// must be safe for concurrent use.
+//
+// Embed NopTracer to implement only a subset of the callbacks.
+type Tracer interface {
+	// TxBegin is called once a transaction has been initialized.
+	TxBegin(tx *Tx)
+
+	// TxLockAcquired is called after a transaction obtains its locks. For
+	// writable transactions wait is the time spent waiting on the writer
+	// lock. For read-only transactions it is the time spent waiting on the
+	// meta and mmap locks.
+	TxLockAcquired(tx *Tx, wait time.Duration)
+
+	// TxRebalance is called after nodes have been rebalanced during commit.
+	// n is the number of node rebalances performed.
+	TxRebalance(tx *Tx, d time.Duration, n int)
+
+	// TxSpill is called after nodes have been spilled onto dirty pages
+	// during commit. n is the number of nodes spilled.
+	TxSpill(tx *Tx, d time.Duration, n int)
+
+	// TxWriteFreelist is called after the freelist has been written onto
+	// dirty pages during commit. n is the number of pages allocated for it.
+	TxWriteFreelist(tx *Tx, d time.Duration, n int)
+
+	// TxWritePages is called after dirty pages have been written to the
+	// data file. n is the number of pages written, including overflow pages.
+	TxWritePages(tx *Tx, d time.Duration, n int)
+
+	// TxSync is called after each fdatasync() performed during commit.
+	TxSync(tx *Tx, d time.Duration)
+
+	// TxWriteMeta is called after the meta page has been written.
+	TxWriteMeta(tx *Tx, d time.Duration)
+
+	// TxCommit is called when a commit finishes. d is the total time spent
+	// committing and err is the error returned from Commit, if any.
+	TxCommit(tx *Tx, d time.Duration, err error)
+
+	// Remap is called after the data file has been memory mapped. d
+	// includes the time spent waiting for open read transactions to close.
+	Remap(oldSize, newSize int, d time.Duration)
+}
+
+// NopTracer is a Tracer that ignores all callbacks. It is the default
+// tracer and can be embedded to implement a subset of the Tracer interface.
+type NopTracer struct{}
+
+func (NopTracer) TxBegin(tx *Tx)                                 {}
+func (NopTracer) TxLockAcquired(tx *Tx, wait time.Duration)      {}
+func (NopTracer) TxRebalance(tx *Tx, d time.Duration, n int)     {}
+func (NopTracer) TxSpill(tx *Tx, d time.Duration, n int)         {}
+func (NopTracer) TxWriteFreelist(tx *Tx, d time.Duration, n int) {}
+func (NopTracer) TxWritePages(tx *Tx, d time.Duration, n int)    {}
+func (NopTracer) TxSync(tx *Tx, d time.Duration)                 {}
+func (NopTracer) TxWriteMeta(tx *Tx, d time.Duration)            {}
+func (NopTracer) TxCommit(tx *Tx, d time.Duration, err error)    {}
+func (NopTracer) Remap(oldSize, newSize int, d time.Duration)    {}
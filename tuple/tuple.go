@@ -0,0 +1,296 @@
+/*
+Package tuple encodes tuples of values into byte keys whose lexicographic
+order matches the order of the tuples.
+
+Bolt sorts keys with bytes.Compare so composite keys built by concatenating
+fields often sort incorrectly, for example when a negative integer or a
+variable length string is involved. Pack produces keys that sort element by
+element:
+
+	key, err := tuple.Pack("acme", time.Now(), uint64(42))
+
+The following element types are supported: []byte, string, all signed and
+unsigned integer types, float32, float64, bool and time.Time. Elements of
+different types sort by type in that order, except that signed and unsigned
+integers share a single ordering. Unpack decodes integers as int64, or as
+uint64 when the value is larger than math.MaxInt64, floats as float64 and
+times in UTC.
+
+Range returns the keys covering every tuple that starts with a given prefix,
+which can be used to iterate with a cursor:
+
+	r, err := tuple.Range("acme")
+	c := bucket.Cursor()
+	for k, v := c.Seek(r.Start); k != nil && r.Contains(k); k, v = c.Next() {
+		...
+	}
+*/
+package tuple
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Type codes written before each element. They determine the order of
+// elements of different types.
+const (
+	bytesCode  = 0x01
+	stringCode = 0x02
+	negIntCode = 0x14
+	posIntCode = 0x15
+	floatCode  = 0x21
+	falseCode  = 0x26
+	trueCode   = 0x27
+	timeCode   = 0x30
+)
+
+// Tuple is a decoded tuple.
+type Tuple []interface{}
+
+// Pack encodes the elements into an order-preserving key.
+// Returns an error if an element has an unsupported type.
+func Pack(elems ...interface{}) ([]byte, error) {
+	return Append(nil, elems...)
+}
+
+// MustPack is like Pack but panics if an element cannot be encoded.
+func MustPack(elems ...interface{}) []byte {
+	b, err := Pack(elems...)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// Append encodes the elements and appends them to dst.
+func Append(dst []byte, elems ...interface{}) ([]byte, error) {
+	for i, e := range elems {
+		switch v := e.(type) {
+		case []byte:
+			dst = appendBytes(append(dst, bytesCode), v)
+		case string:
+			dst = appendBytes(append(dst, stringCode), []byte(v))
+		case int:
+			dst = appendInt(dst, int64(v))
+		case int8:
+			dst = appendInt(dst, int64(v))
+		case int16:
+			dst = appendInt(dst, int64(v))
+		case int32:
+			dst = appendInt(dst, int64(v))
+		case int64:
+			dst = appendInt(dst, v)
+		case uint:
+			dst = appendUint(dst, uint64(v))
+		case uint8:
+			dst = appendUint(dst, uint64(v))
+		case uint16:
+			dst = appendUint(dst, uint64(v))
+		case uint32:
+			dst = appendUint(dst, uint64(v))
+		case uint64:
+			dst = appendUint(dst, v)
+		case float32:
+			dst = appendFloat(dst, float64(v))
+		case float64:
+			dst = appendFloat(dst, v)
+		case bool:
+			if v {
+				dst = append(dst, trueCode)
+			} else {
+				dst = append(dst, falseCode)
+			}
+		case time.Time:
+			dst = appendUint64(append(dst, timeCode), uint64(v.Unix())^(1<<63))
+			dst = appendUint32(dst, uint32(v.Nanosecond()))
+		default:
+			return nil, fmt.Errorf("element %d: unsupported type: %T", i, e)
+		}
+	}
+	return dst, nil
+}
+
+// appendBytes appends b with each 0x00 escaped as 0x00 0xFF followed by a
+// 0x00 terminator so that shorter values sort first.
+func appendBytes(dst, b []byte) []byte {
+	for _, c := range b {
+		dst = append(dst, c)
+		if c == 0x00 {
+			dst = append(dst, 0xFF)
+		}
+	}
+	return append(dst, 0x00)
+}
+
+// appendInt appends a signed integer. Negative integers are stored as their
+// two's complement which sorts correctly among other negative integers.
+func appendInt(dst []byte, v int64) []byte {
+	if v >= 0 {
+		return appendUint(dst, uint64(v))
+	}
+	return appendUint64(append(dst, negIntCode), uint64(v))
+}
+
+// appendUint appends a non-negative integer.
+func appendUint(dst []byte, v uint64) []byte {
+	return appendUint64(append(dst, posIntCode), v)
+}
+
+// appendFloat appends a float. Negative floats have every bit flipped and
+// positive floats have the sign bit flipped.
+func appendFloat(dst []byte, v float64) []byte {
+	bits := math.Float64bits(v)
+	if bits&(1<<63) != 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+	return appendUint64(append(dst, floatCode), bits)
+}
+
+func appendUint64(dst []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	return append(dst, buf[:]...)
+}
+
+func appendUint32(dst []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	return append(dst, buf[:]...)
+}
+
+// Unpack decodes a key produced by Pack.
+func Unpack(b []byte) (Tuple, error) {
+	var t Tuple
+	for i := 0; i < len(b); {
+		code := b[i]
+		i++
+
+		switch code {
+		case bytesCode, stringCode:
+			v, n, err := unpackBytes(b[i:])
+			if err != nil {
+				return nil, fmt.Errorf("offset %d: %s", i-1, err)
+			}
+			i += n
+			if code == stringCode {
+				t = append(t, string(v))
+			} else {
+				t = append(t, v)
+			}
+
+		case negIntCode, posIntCode, floatCode:
+			if len(b)-i < 8 {
+				return nil, fmt.Errorf("offset %d: truncated number", i-1)
+			}
+			u := binary.BigEndian.Uint64(b[i:])
+			i += 8
+
+			switch {
+			case code == negIntCode:
+				t = append(t, int64(u))
+			case code == posIntCode && u > math.MaxInt64:
+				t = append(t, u)
+			case code == posIntCode:
+				t = append(t, int64(u))
+			default:
+				if u&(1<<63) != 0 {
+					u &^= 1 << 63
+				} else {
+					u = ^u
+				}
+				t = append(t, math.Float64frombits(u))
+			}
+
+		case falseCode:
+			t = append(t, false)
+		case trueCode:
+			t = append(t, true)
+
+		case timeCode:
+			if len(b)-i < 12 {
+				return nil, fmt.Errorf("offset %d: truncated time", i-1)
+			}
+			sec := int64(binary.BigEndian.Uint64(b[i:]) ^ (1 << 63))
+			nsec := int64(binary.BigEndian.Uint32(b[i+8:]))
+			i += 12
+			t = append(t, time.Unix(sec, nsec).UTC())
+
+		default:
+			return nil, fmt.Errorf("offset %d: unknown type code: 0x%02x", i-1, code)
+		}
+	}
+	return t, nil
+}
+
+// unpackBytes decodes an escaped byte string and returns the number of bytes
+// consumed, including the terminator.
+func unpackBytes(b []byte) ([]byte, int, error) {
+	var v []byte
+	for i := 0; i < len(b); i++ {
+		if b[i] != 0x00 {
+			v = append(v, b[i])
+		} else if i+1 < len(b) && b[i+1] == 0xFF {
+			v = append(v, 0x00)
+			i++
+		} else {
+			if v == nil {
+				v = []byte{}
+			}
+			return v, i + 1, nil
+		}
+	}
+	return nil, 0, fmt.Errorf("unterminated string")
+}
+
+// KeyRange represents the half-open range of keys [Start, End).
+// A nil End means the range is unbounded.
+type KeyRange struct {
+	Start []byte
+	End   []byte
+}
+
+// Contains returns true if k is within the range.
+func (r KeyRange) Contains(k []byte) bool {
+	if bytes.Compare(k, r.Start) < 0 {
+		return false
+	}
+	return r.End == nil || bytes.Compare(k, r.End) < 0
+}
+
+// PrefixRange returns the range of keys starting with prefix.
+func PrefixRange(prefix []byte) KeyRange {
+	r := KeyRange{Start: append([]byte{}, prefix...)}
+
+	// The end is the prefix with its last byte incremented, after trimming
+	// any trailing 0xFF bytes. If every byte is 0xFF then there is no end.
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] != 0xFF {
+			r.End = append([]byte{}, prefix[:i+1]...)
+			r.End[i]++
+			break
+		}
+	}
+	return r
+}
+
+// Range returns the range of keys for every tuple that begins with the
+// given elements.
+//
+// The range is not the same as PrefixRange of the packed elements. A string
+// or byte slice that contains 0x00 continues with the escape byte 0xFF after
+// the terminator of a shorter string, so ("a\x00b") shares the bytes of ("a")
+// followed by 0xFF. Every type code is below 0xFF so the range ends at the
+// packed elements followed by 0xFF instead.
+func Range(elems ...interface{}) (KeyRange, error) {
+	prefix, err := Pack(elems...)
+	if err != nil {
+		return KeyRange{}, err
+	}
+	return KeyRange{Start: prefix, End: append(prefix[:len(prefix):len(prefix)], 0xFF)}, nil
+}
@@ -0,0 +1,168 @@
+package tuple_test
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt/tuple"
+)
+
+// Ensure that tuples round trip through Pack and Unpack.
+func TestUnpack(t *testing.T) {
+	tm := time.Date(2016, 6, 1, 12, 30, 0, 5, time.UTC)
+	for _, tt := range []struct {
+		in  []interface{}
+		out tuple.Tuple
+	}{
+		{[]interface{}{"foo", []byte("a\x00b"), ""}, tuple.Tuple{"foo", []byte("a\x00b"), ""}},
+		{[]interface{}{int8(-5), uint16(7), int(0)}, tuple.Tuple{int64(-5), int64(7), int64(0)}},
+		{[]interface{}{int64(math.MinInt64), uint64(math.MaxUint64)}, tuple.Tuple{int64(math.MinInt64), uint64(math.MaxUint64)}},
+		{[]interface{}{float32(1.5), -0.25, math.Inf(-1)}, tuple.Tuple{1.5, -0.25, math.Inf(-1)}},
+		{[]interface{}{true, false, tm}, tuple.Tuple{true, false, tm}},
+	} {
+		b, err := tuple.Pack(tt.in...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := tuple.Unpack(b)
+		if err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(out, tt.out) {
+			t.Fatalf("unexpected tuple: %#v", out)
+		}
+	}
+}
+
+// Ensure that packed tuples sort in tuple order.
+func TestPack_Order(t *testing.T) {
+	tuples := [][]interface{}{
+		{[]byte{}},
+		{[]byte{0x00}},
+		{[]byte{0x00, 0x00}},
+		{[]byte{0x01}},
+		{"a"},
+		{"a", int64(math.MinInt64)},
+		{"a", -1000},
+		{"a", -1},
+		{"a", 0},
+		{"a", uint8(1)},
+		{"a", 1, "x"},
+		{"a", 2},
+		{"a", uint64(math.MaxUint64)},
+		{"a", math.Inf(-1)},
+		{"a", -1.5},
+		{"a", 0.0},
+		{"a", 1e-300},
+		{"a", math.Inf(1)},
+		{"a", false},
+		{"a", true},
+		{"a", time.Unix(-1, 0)},
+		{"a", time.Unix(0, 0)},
+		{"a", time.Unix(0, 1)},
+		{"a\x00"},
+		{"ab"},
+		{"b"},
+	}
+
+	keys := make([][]byte, len(tuples))
+	for i, tup := range tuples {
+		keys[i] = tuple.MustPack(tup...)
+	}
+	for i := 1; i < len(keys); i++ {
+		if bytes.Compare(keys[i-1], keys[i]) >= 0 {
+			t.Fatalf("out of order: %v >= %v", tuples[i-1], tuples[i])
+		}
+	}
+}
+
+// Ensure that unsupported types and invalid keys return an error.
+func TestPack_Errors(t *testing.T) {
+	if _, err := tuple.Pack("a", struct{}{}); err == nil || err.Error() != "element 1: unsupported type: struct {}" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := tuple.Unpack([]byte{0x02, 'a'}); err == nil {
+		t.Fatal("expected error for unterminated string")
+	}
+	if _, err := tuple.Unpack([]byte{0x15, 0x00}); err == nil {
+		t.Fatal("expected error for truncated integer")
+	}
+	if _, err := tuple.Unpack([]byte{0xEE}); err == nil {
+		t.Fatal("expected error for unknown type code")
+	}
+}
+
+// Ensure that a range contains exactly the tuples beginning with a prefix.
+func TestRange(t *testing.T) {
+	r, err := tuple.Range("acme", 2016)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		key []interface{}
+		exp bool
+	}{
+		{[]interface{}{"acme", 2016}, true},
+		{[]interface{}{"acme", 2016, "x"}, true},
+		{[]interface{}{"acme", 2016, uint64(math.MaxUint64)}, true},
+		{[]interface{}{"acme", 2015, "x"}, false},
+		{[]interface{}{"acme", 2017}, false},
+		{[]interface{}{"acme"}, false},
+		{[]interface{}{"acmf", 2016}, false},
+	} {
+		if v := r.Contains(tuple.MustPack(tt.key...)); v != tt.exp {
+			t.Fatalf("%v: unexpected result: %v", tt.key, v)
+		}
+	}
+}
+
+// Ensure that a range excludes tuples whose string or byte slice element
+// only begins with the prefix element followed by a NUL.
+func TestRange_NUL(t *testing.T) {
+	for _, tt := range []struct {
+		prefix []interface{}
+		key    []interface{}
+		exp    bool
+	}{
+		{[]interface{}{"acme"}, []interface{}{"acme"}, true},
+		{[]interface{}{"acme"}, []interface{}{"acme", 1}, true},
+		{[]interface{}{"acme"}, []interface{}{"acme", "\x00"}, true},
+		{[]interface{}{"acme"}, []interface{}{"acme\x00evil", 1}, false},
+		{[]interface{}{"acme"}, []interface{}{"acme\x00"}, false},
+		{[]interface{}{"a\x00b"}, []interface{}{"a\x00b", 1}, true},
+		{[]interface{}{"a\x00b"}, []interface{}{"a\x00b\x00c"}, false},
+		{[]interface{}{"a"}, []interface{}{"a\x00b"}, false},
+		{[]interface{}{[]byte("k")}, []interface{}{[]byte("k"), []byte{0x00}}, true},
+		{[]interface{}{[]byte("k")}, []interface{}{[]byte("k\x00"), 1}, false},
+		{[]interface{}{[]byte{0x00}}, []interface{}{[]byte{0x00}, true}, true},
+		{[]interface{}{[]byte{0x00}}, []interface{}{[]byte{0x00, 0x00}}, false},
+		{[]interface{}{"acme", 1}, []interface{}{"acme", 1, "x\x00"}, true},
+		{[]interface{}{"acme", 1}, []interface{}{"acme", 2}, false},
+	} {
+		r, err := tuple.Range(tt.prefix...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := r.Contains(tuple.MustPack(tt.key...)); v != tt.exp {
+			t.Fatalf("%q in %q: unexpected result: %v", tt.key, tt.prefix, v)
+		}
+	}
+}
+
+// Ensure that a prefix of only 0xFF bytes has no end.
+func TestPrefixRange_Unbounded(t *testing.T) {
+	r := tuple.PrefixRange([]byte{0xFF, 0xFF})
+	if r.End != nil {
+		t.Fatalf("unexpected end: %x", r.End)
+	} else if !r.Contains([]byte{0xFF, 0xFF, 0xFF}) {
+		t.Fatal("expected key to be contained")
+	}
+
+	r = tuple.PrefixRange([]byte{0x01, 0xFF})
+	if !bytes.Equal(r.End, []byte{0x02}) {
+		t.Fatalf("unexpected end: %x", r.End)
+	}
+}
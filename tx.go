@@ -1,6 +1,7 @@
 package bolt
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
@@ -30,6 +31,7 @@ type Tx struct {
 	pages          map[pgid]*page
 	stats          TxStats
 	commitHandlers []func()
+	writeBytes     int // total size of keys and values written, used to split batches
 
 	// WriteFlag specifies the flag for write-related methods like WriteTo().
 	// Tx opens the database file with the specified flag to copy the data.
@@ -121,6 +123,80 @@ func (tx *Tx) DeleteBucket(name []byte) error {
 	return tx.root.DeleteBucket(name)
 }
 
+// BucketPath retrieves a nested bucket by following a path of bucket names
+// from the root. Returns a *PathError wrapping ErrBucketNotFound if an element
+// does not exist or ErrIncompatibleValue if it is not a bucket.
+// The bucket instance is only valid for the lifetime of the transaction.
+func (tx *Tx) BucketPath(names ...[]byte) (*Bucket, error) {
+	if tx.db == nil {
+		return nil, ErrTxClosed
+	} else if len(names) == 0 {
+		return nil, ErrBucketNameRequired
+	}
+
+	b := &tx.root
+	for i, name := range names {
+		child, err := b.lookupBucket(name)
+		if err != nil {
+			return nil, &PathError{Path: names[:i+1], Err: err}
+		}
+		b = child
+	}
+	return b, nil
+}
+
+// CreateBucketPath creates every bucket along a path of bucket names that
+// doesn't already exist and returns the last one. Existing buckets are reused.
+// Returns a *PathError if an element is blank, too long or an existing
+// non-bucket key.
+// The bucket instance is only valid for the lifetime of the transaction.
+func (tx *Tx) CreateBucketPath(names ...[]byte) (*Bucket, error) {
+	if tx.db == nil {
+		return nil, ErrTxClosed
+	} else if !tx.writable {
+		return nil, ErrTxNotWritable
+	} else if len(names) == 0 {
+		return nil, ErrBucketNameRequired
+	}
+
+	b := &tx.root
+	for i, name := range names {
+		child, err := b.CreateBucketIfNotExists(name)
+		if err != nil {
+			return nil, &PathError{Path: names[:i+1], Err: err}
+		}
+		b = child
+	}
+	return b, nil
+}
+
+// DeleteBucketPath deletes the last bucket in a path of bucket names.
+// Returns a *PathError wrapping ErrBucketNotFound if an element does not
+// exist or ErrIncompatibleValue if it is not a bucket.
+func (tx *Tx) DeleteBucketPath(names ...[]byte) error {
+	if tx.db == nil {
+		return ErrTxClosed
+	} else if !tx.writable {
+		return ErrTxNotWritable
+	} else if len(names) == 0 {
+		return ErrBucketNameRequired
+	}
+
+	parent := &tx.root
+	if len(names) > 1 {
+		b, err := tx.BucketPath(names[:len(names)-1]...)
+		if err != nil {
+			return err
+		}
+		parent = b
+	}
+
+	if err := parent.DeleteBucket(names[len(names)-1]); err != nil {
+		return &PathError{Path: names, Err: err}
+	}
+	return nil
+}
+
 // ForEach executes a function for each bucket in the root.
 // If the provided function returns an error then the iteration is stopped and
 // the error is returned to the caller.
@@ -150,21 +226,27 @@ func (tx *Tx) Commit() error {
 	}
 
 	// TODO(benbjohnson): Use vectorized I/O to write out dirty pages.
+	var tracer = tx.db.tracer
+	var commitTime = time.Now()
 
 	// Rebalance nodes which have had deletions.
 	var startTime = time.Now()
+	var rebalanceN = tx.stats.Rebalance
 	tx.root.rebalance()
-	if tx.stats.Rebalance > 0 {
+	if tx.stats.Rebalance > rebalanceN {
 		tx.stats.RebalanceTime += time.Since(startTime)
+		tracer.TxRebalance(tx, time.Since(startTime), tx.stats.Rebalance-rebalanceN)
 	}
 
 	// spill data onto dirty pages.
 	startTime = time.Now()
+	var spillN = tx.stats.Spill
 	if err := tx.root.spill(); err != nil {
-		tx.rollback()
+		tx.abort(commitTime, err)
 		return err
 	}
 	tx.stats.SpillTime += time.Since(startTime)
+	tracer.TxSpill(tx, time.Since(startTime), tx.stats.Spill-spillN)
 
 	// Free the old root bucket.
 	tx.meta.root.root = tx.root.root
@@ -173,22 +255,24 @@ func (tx *Tx) Commit() error {
 
 	// Free the freelist and allocate new pages for it. This will overestimate
 	// the size of the freelist but not underestimate the size (which would be bad).
+	startTime = time.Now()
 	tx.db.freelist.free(tx.meta.txid, tx.db.page(tx.meta.freelist))
 	p, err := tx.allocate((tx.db.freelist.size() / tx.db.pageSize) + 1)
 	if err != nil {
-		tx.rollback()
+		tx.abort(commitTime, err)
 		return err
 	}
 	if err := tx.db.freelist.write(p); err != nil {
-		tx.rollback()
+		tx.abort(commitTime, err)
 		return err
 	}
 	tx.meta.freelist = p.id
+	tracer.TxWriteFreelist(tx, time.Since(startTime), int(p.overflow)+1)
 
 	// If the high water mark has moved up then attempt to grow the database.
 	if tx.meta.pgid > opgid {
 		if err := tx.db.grow(int(tx.meta.pgid+1) * tx.db.pageSize); err != nil {
-			tx.rollback()
+			tx.abort(commitTime, err)
 			return err
 		}
 	}
@@ -196,7 +280,7 @@ func (tx *Tx) Commit() error {
 	// Write dirty pages to disk.
 	startTime = time.Now()
 	if err := tx.write(); err != nil {
-		tx.rollback()
+		tx.abort(commitTime, err)
 		return err
 	}
 
@@ -213,16 +297,20 @@ func (tx *Tx) Commit() error {
 			errs = append(errs, err.Error())
 		}
 		if len(errs) > 0 {
+			for _, err := range errs {
+				tx.db.logger.Errorf("bolt: strict mode check failed: %s", err)
+			}
 			panic("check fail: " + strings.Join(errs, "\n"))
 		}
 	}
 
 	// Write meta to disk.
 	if err := tx.writeMeta(); err != nil {
-		tx.rollback()
+		tx.abort(commitTime, err)
 		return err
 	}
 	tx.stats.WriteTime += time.Since(startTime)
+	tracer.TxCommit(tx, time.Since(commitTime), nil)
 
 	// Finalize the transaction.
 	tx.close()
@@ -235,6 +323,12 @@ func (tx *Tx) Commit() error {
 	return nil
 }
 
+// abort reports a failed commit to the tracer and rolls back the transaction.
+func (tx *Tx) abort(commitTime time.Time, err error) {
+	tx.db.tracer.TxCommit(tx, time.Since(commitTime), err)
+	tx.rollback()
+}
+
 // Rollback closes the transaction and ignores all previous updates. Read-only
 // transactions must be rolled back and not committed.
 func (tx *Tx) Rollback() error {
@@ -302,12 +396,20 @@ func (tx *Tx) Copy(w io.Writer) error {
 // WriteTo writes the entire database to a writer.
 // If err == nil then exactly tx.Size() bytes will be written into the writer.
 func (tx *Tx) WriteTo(w io.Writer) (n int64, err error) {
-	// Attempt to open reader with WriteFlag
-	f, err := os.OpenFile(tx.db.path, os.O_RDONLY|tx.WriteFlag, 0)
-	if err != nil {
-		return 0, err
+	// Databases opened from memory are copied directly from their data.
+	var r io.Reader
+	var f *os.File
+	if tx.db.memdata != nil {
+		r = bytes.NewReader(tx.db.memdata[tx.db.pageSize*2:])
+	} else {
+		// Attempt to open reader with WriteFlag
+		f, err = os.OpenFile(tx.db.path, os.O_RDONLY|tx.WriteFlag, 0)
+		if err != nil {
+			return 0, err
+		}
+		defer func() { _ = f.Close() }()
+		r = f
 	}
-	defer func() { _ = f.Close() }()
 
 	// Generate a meta page. We use the same page data for both meta pages.
 	buf := make([]byte, tx.db.pageSize)
@@ -335,17 +437,22 @@ func (tx *Tx) WriteTo(w io.Writer) (n int64, err error) {
 	}
 
 	// Move past the meta pages in the file.
-	if _, err := f.Seek(int64(tx.db.pageSize*2), os.SEEK_SET); err != nil {
-		return n, fmt.Errorf("seek: %s", err)
+	if f != nil {
+		if _, err := f.Seek(int64(tx.db.pageSize*2), os.SEEK_SET); err != nil {
+			return n, fmt.Errorf("seek: %s", err)
+		}
 	}
 
 	// Copy data pages.
-	wn, err := io.CopyN(w, f, tx.Size()-int64(tx.db.pageSize*2))
+	wn, err := io.CopyN(w, r, tx.Size()-int64(tx.db.pageSize*2))
 	n += wn
 	if err != nil {
 		return n, err
 	}
 
+	if f == nil {
+		return n, nil
+	}
 	return n, f.Close()
 }
 
@@ -482,7 +589,10 @@ func (tx *Tx) write() error {
 	sort.Sort(pages)
 
 	// Write pages to disk in order.
+	var startTime = time.Now()
+	var pageN int
 	for _, p := range pages {
+		pageN += int(p.overflow) + 1
 		size := (int(p.overflow) + 1) * tx.db.pageSize
 		offset := int64(p.id) * int64(tx.db.pageSize)
 
@@ -516,9 +626,11 @@ func (tx *Tx) write() error {
 		}
 	}
 
+	tx.db.tracer.TxWritePages(tx, time.Since(startTime), pageN)
+
 	// Ignore file sync if flag is set on DB.
 	if !tx.db.NoSync || IgnoreNoSync {
-		if err := fdatasync(tx.db); err != nil {
+		if err := tx.sync(); err != nil {
 			return err
 		}
 	}
@@ -551,11 +663,14 @@ func (tx *Tx) writeMeta() error {
 	tx.meta.write(p)
 
 	// Write the meta page to file.
+	var startTime = time.Now()
 	if _, err := tx.db.ops.writeAt(buf, int64(p.id)*int64(tx.db.pageSize)); err != nil {
 		return err
 	}
+	tx.db.tracer.TxWriteMeta(tx, time.Since(startTime))
+
 	if !tx.db.NoSync || IgnoreNoSync {
-		if err := fdatasync(tx.db); err != nil {
+		if err := tx.sync(); err != nil {
 			return err
 		}
 	}
@@ -566,6 +681,16 @@ func (tx *Tx) writeMeta() error {
 	return nil
 }
 
+// sync executes fdatasync() against the database file and reports its duration.
+func (tx *Tx) sync() error {
+	var startTime = time.Now()
+	if err := fdatasync(tx.db); err != nil {
+		return err
+	}
+	tx.db.tracer.TxSync(tx, time.Since(startTime))
+	return nil
+}
+
 // page returns a reference to the page with a given id.
 // If page has been written to then a temporary buffered page is returned.
 func (tx *Tx) page(id pgid) *page {
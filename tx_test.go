@@ -6,7 +6,10 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/boltdb/bolt"
 )
@@ -387,6 +390,121 @@ func TestTx_DeleteBucket_NotFound(t *testing.T) {
 	}
 }
 
+// Ensure that nested buckets can be created, retrieved and deleted by path.
+func TestTx_BucketPath(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketPath([]byte("a"), []byte("b"), []byte("c"))
+		if err != nil {
+			t.Fatal(err)
+		} else if err := b.Put([]byte("foo"), []byte("bar")); err != nil {
+			t.Fatal(err)
+		}
+
+		// Existing buckets are reused.
+		if _, err := tx.CreateBucketPath([]byte("a"), []byte("b"), []byte("d")); err != nil {
+			t.Fatal(err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.View(func(tx *bolt.Tx) error {
+		b, err := tx.BucketPath([]byte("a"), []byte("b"), []byte("c"))
+		if err != nil {
+			t.Fatal(err)
+		} else if v := b.Get([]byte("foo")); !bytes.Equal(v, []byte("bar")) {
+			t.Fatalf("unexpected value: %v", v)
+		} else if b := tx.Bucket([]byte("a")).Bucket([]byte("b")).Bucket([]byte("d")); b == nil {
+			t.Fatal("expected bucket")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucketPath([]byte("a"), []byte("b"), []byte("c")); err != nil {
+			t.Fatal(err)
+		} else if _, err := tx.BucketPath([]byte("a"), []byte("b"), []byte("c")); err == nil {
+			t.Fatal("expected error")
+		} else if _, err := tx.BucketPath([]byte("a"), []byte("b"), []byte("d")); err != nil {
+			t.Fatal(err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure that path errors name the failing element.
+func TestTx_BucketPath_PathError(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketPath([]byte("a"), []byte("b"))
+		if err != nil {
+			t.Fatal(err)
+		} else if err := b.Put([]byte("key"), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = tx.BucketPath([]byte("a"), []byte("x"), []byte("y"))
+		if e, ok := err.(*bolt.PathError); !ok || e.Err != bolt.ErrBucketNotFound {
+			t.Fatalf("unexpected error: %v", err)
+		} else if e.Error() != `bucket "a"/"x": bucket not found` {
+			t.Fatalf("unexpected message: %s", e)
+		}
+
+		_, err = tx.BucketPath([]byte("a"), []byte("b"), []byte("key"))
+		if e, ok := err.(*bolt.PathError); !ok || e.Err != bolt.ErrIncompatibleValue {
+			t.Fatalf("unexpected error: %v", err)
+		} else if len(e.Path) != 3 {
+			t.Fatalf("unexpected path: %q", e.Path)
+		}
+
+		_, err = tx.CreateBucketPath([]byte("a"), []byte("b"), []byte("key"), []byte("c"))
+		if e, ok := err.(*bolt.PathError); !ok || e.Err != bolt.ErrIncompatibleValue {
+			t.Fatalf("unexpected error: %v", err)
+		} else if e.Error() != `bucket "a"/"b"/"key": incompatible value` {
+			t.Fatalf("unexpected message: %s", e)
+		}
+
+		err = tx.DeleteBucketPath([]byte("a"), []byte("b"), []byte("key"))
+		if e, ok := err.(*bolt.PathError); !ok || e.Err != bolt.ErrIncompatibleValue {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		err = tx.DeleteBucketPath([]byte("x"), []byte("b"))
+		if e, ok := err.(*bolt.PathError); !ok || e.Err != bolt.ErrBucketNotFound || len(e.Path) != 1 {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if _, err := tx.BucketPath(); err != bolt.ErrBucketNameRequired {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure that creating a bucket path on a read-only transaction returns an error.
+func TestTx_CreateBucketPath_ErrTxNotWritable(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+	if err := db.View(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketPath([]byte("a")); err != bolt.ErrTxNotWritable {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
 // Ensure that no error is returned when a tx.ForEach function does not return
 // an error.
 func TestTx_ForEach_NoError(t *testing.T) {
@@ -477,6 +595,33 @@ func TestTx_OnCommit_Rollback(t *testing.T) {
 	}
 }
 
+// Ensure that the tracer receives a callback for each phase of a commit.
+func TestTx_Commit_Tracer(t *testing.T) {
+	var tracer testTracer
+	db, err := bolt.Open(tempfile(), 0666, &bolt.Options{Tracer: &tracer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(db.Path())
+	defer db.Close()
+
+	tracer.Reset()
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte("foo"), []byte("bar"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{"begin", "lock", "spill", "freelist", "write", "sync", "meta", "sync", "commit"}
+	if got := tracer.Events(); !reflect.DeepEqual(got, exp) {
+		t.Fatalf("unexpected events: %v", got)
+	}
+}
+
 // Ensure that the database can be copied to a file path.
 func TestTx_CopyFile(t *testing.T) {
 	db := MustOpenDB()
@@ -714,3 +859,41 @@ func ExampleTx_CopyFile() {
 	// Output:
 	// The value for 'foo' in the clone is: bar
 }
+
+// testTracer is a bolt.Tracer that records the name of each event.
+type testTracer struct {
+	bolt.NopTracer
+	mu     sync.Mutex
+	events []string
+}
+
+func (t *testTracer) TxBegin(tx *bolt.Tx)                                 { t.add("begin") }
+func (t *testTracer) TxLockAcquired(tx *bolt.Tx, wait time.Duration)      { t.add("lock") }
+func (t *testTracer) TxRebalance(tx *bolt.Tx, d time.Duration, n int)     { t.add("rebalance") }
+func (t *testTracer) TxSpill(tx *bolt.Tx, d time.Duration, n int)         { t.add("spill") }
+func (t *testTracer) TxWriteFreelist(tx *bolt.Tx, d time.Duration, n int) { t.add("freelist") }
+func (t *testTracer) TxWritePages(tx *bolt.Tx, d time.Duration, n int)    { t.add("write") }
+func (t *testTracer) TxSync(tx *bolt.Tx, d time.Duration)                 { t.add("sync") }
+func (t *testTracer) TxWriteMeta(tx *bolt.Tx, d time.Duration)            { t.add("meta") }
+func (t *testTracer) TxCommit(tx *bolt.Tx, d time.Duration, err error)    { t.add("commit") }
+func (t *testTracer) Remap(oldSize, newSize int, d time.Duration)         { t.add("remap") }
+
+func (t *testTracer) add(event string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, event)
+}
+
+// Events returns a copy of the recorded events.
+func (t *testTracer) Events() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string(nil), t.events...)
+}
+
+// Reset clears all recorded events.
+func (t *testTracer) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = nil
+}
@@ -0,0 +1,183 @@
+//go:build go1.18
+// +build go1.18
+
+package bolt
+
+// Codec encodes and decodes values of type T to and from bytes.
+// Codecs used for keys must preserve the order of T under bytes.Compare
+// so that cursors iterate in the expected order.
+type Codec[T any] interface {
+	Encode(v T) ([]byte, error)
+	Decode(b []byte) (T, error)
+}
+
+// TypedBucket wraps a Bucket and converts keys and values using codecs.
+// Nested buckets are skipped by ForEach and cursors.
+//
+// A TypedBucket is only valid for the lifetime of the transaction that
+// created the underlying bucket.
+type TypedBucket[K, V any] struct {
+	bucket *Bucket
+	keys   Codec[K]
+	values Codec[V]
+}
+
+// NewTypedBucket returns a typed wrapper for b using the given key and value codecs.
+func NewTypedBucket[K, V any](b *Bucket, keys Codec[K], values Codec[V]) *TypedBucket[K, V] {
+	return &TypedBucket[K, V]{bucket: b, keys: keys, values: values}
+}
+
+// Bucket returns the underlying bucket.
+func (b *TypedBucket[K, V]) Bucket() *Bucket {
+	return b.bucket
+}
+
+// Get retrieves and decodes the value for a key.
+// Returns ok as false if the key does not exist or is a nested bucket.
+func (b *TypedBucket[K, V]) Get(key K) (value V, ok bool, err error) {
+	k, err := b.keys.Encode(key)
+	if err != nil {
+		return value, false, err
+	}
+
+	v := b.bucket.Get(k)
+	if v == nil {
+		return value, false, nil
+	}
+
+	value, err = b.values.Decode(v)
+	if err != nil {
+		return value, false, err
+	}
+	return value, true, nil
+}
+
+// Put encodes and sets the value for a key. See Bucket.Put for details.
+func (b *TypedBucket[K, V]) Put(key K, value V) error {
+	k, err := b.keys.Encode(key)
+	if err != nil {
+		return err
+	}
+	v, err := b.values.Encode(value)
+	if err != nil {
+		return err
+	}
+	return b.bucket.Put(k, v)
+}
+
+// Delete removes a key from the bucket. See Bucket.Delete for details.
+func (b *TypedBucket[K, V]) Delete(key K) error {
+	k, err := b.keys.Encode(key)
+	if err != nil {
+		return err
+	}
+	return b.bucket.Delete(k)
+}
+
+// ForEach decodes and executes a function for each key/value pair in the
+// bucket. If the provided function returns an error then the iteration is
+// stopped and the error is returned to the caller.
+func (b *TypedBucket[K, V]) ForEach(fn func(k K, v V) error) error {
+	return b.bucket.ForEach(func(k, v []byte) error {
+		if v == nil {
+			return nil
+		}
+		key, value, err := b.decode(k, v)
+		if err != nil {
+			return err
+		}
+		return fn(key, value)
+	})
+}
+
+// Cursor creates a typed cursor associated with the bucket.
+func (b *TypedBucket[K, V]) Cursor() *TypedCursor[K, V] {
+	return &TypedCursor[K, V]{bucket: b, cursor: b.bucket.Cursor()}
+}
+
+// decode decodes a raw key/value pair.
+func (b *TypedBucket[K, V]) decode(k, v []byte) (key K, value V, err error) {
+	if key, err = b.keys.Decode(k); err != nil {
+		return key, value, err
+	}
+	if value, err = b.values.Decode(v); err != nil {
+		return key, value, err
+	}
+	return key, value, nil
+}
+
+// TypedCursor iterates over the decoded key/value pairs of a TypedBucket.
+//
+// Each positioning method returns ok as false once the cursor moves past
+// either end of the bucket or if an error occurs. Call Err to distinguish
+// between the two.
+type TypedCursor[K, V any] struct {
+	bucket *TypedBucket[K, V]
+	cursor *Cursor
+	err    error
+}
+
+// Cursor returns the underlying cursor.
+func (c *TypedCursor[K, V]) Cursor() *Cursor {
+	return c.cursor
+}
+
+// Err returns the error that stopped the cursor, if any. It is reset when the
+// cursor is repositioned with First, Last or Seek.
+func (c *TypedCursor[K, V]) Err() error {
+	return c.err
+}
+
+// First moves the cursor to the first item in the bucket.
+func (c *TypedCursor[K, V]) First() (key K, value V, ok bool) {
+	c.err = nil
+	k, v := c.cursor.First()
+	return c.skip(k, v, c.cursor.Next)
+}
+
+// Last moves the cursor to the last item in the bucket.
+func (c *TypedCursor[K, V]) Last() (key K, value V, ok bool) {
+	c.err = nil
+	k, v := c.cursor.Last()
+	return c.skip(k, v, c.cursor.Prev)
+}
+
+// Next moves the cursor to the next item in the bucket.
+func (c *TypedCursor[K, V]) Next() (key K, value V, ok bool) {
+	k, v := c.cursor.Next()
+	return c.skip(k, v, c.cursor.Next)
+}
+
+// Prev moves the cursor to the previous item in the bucket.
+func (c *TypedCursor[K, V]) Prev() (key K, value V, ok bool) {
+	k, v := c.cursor.Prev()
+	return c.skip(k, v, c.cursor.Prev)
+}
+
+// Seek moves the cursor to the first key greater than or equal to seek.
+func (c *TypedCursor[K, V]) Seek(seek K) (key K, value V, ok bool) {
+	c.err = nil
+	s, err := c.bucket.keys.Encode(seek)
+	if err != nil {
+		c.err = err
+		return key, value, false
+	}
+	k, v := c.cursor.Seek(s)
+	return c.skip(k, v, c.cursor.Next)
+}
+
+// skip moves past nested buckets using move and decodes the resulting pair.
+func (c *TypedCursor[K, V]) skip(k, v []byte, move func() ([]byte, []byte)) (key K, value V, ok bool) {
+	if c.err != nil {
+		return key, value, false
+	}
+	for k != nil && v == nil {
+		k, v = move()
+	}
+	if k == nil {
+		return key, value, false
+	}
+
+	key, value, c.err = c.bucket.decode(k, v)
+	return key, value, c.err == nil
+}
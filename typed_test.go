@@ -0,0 +1,191 @@
+//go:build go1.18
+// +build go1.18
+
+package bolt_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+type widget struct {
+	Name  string
+	Count int
+}
+
+// Ensure that a typed bucket can store and retrieve values.
+func TestTypedBucket(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := b.CreateBucket([]byte("nested")); err != nil {
+			t.Fatal(err)
+		}
+
+		tb := bolt.NewTypedBucket[string, widget](b, bolt.StringCodec{}, bolt.JSONCodec[widget]{})
+		for _, w := range []widget{{"foo", 1}, {"bar", 2}, {"baz", 3}} {
+			if err := tb.Put(w.Name, w); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := tb.Delete("baz"); err != nil {
+			t.Fatal(err)
+		}
+
+		if w, ok, err := tb.Get("foo"); err != nil {
+			t.Fatal(err)
+		} else if !ok || w != (widget{"foo", 1}) {
+			t.Fatalf("unexpected value: %v, %v", w, ok)
+		} else if _, ok, err := tb.Get("baz"); err != nil || ok {
+			t.Fatalf("unexpected result: %v, %v", ok, err)
+		} else if _, ok, err := tb.Get("nested"); err != nil || ok {
+			t.Fatalf("unexpected result: %v, %v", ok, err)
+		}
+
+		var names []string
+		if err := tb.ForEach(func(k string, v widget) error {
+			names = append(names, k)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		} else if len(names) != 2 || names[0] != "bar" || names[1] != "foo" {
+			t.Fatalf("unexpected names: %v", names)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure that a typed cursor iterates in order of signed integer keys.
+func TestTypedCursor_IntCodec(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tb := bolt.NewTypedBucket[int64, widget](b, bolt.IntCodec[int64]{}, bolt.GobCodec[widget]{})
+		for _, i := range []int64{5, -1, 0, -1 << 40, 1 << 40} {
+			if err := tb.Put(i, widget{Count: int(i % 100)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var keys []int64
+		c := tb.Cursor()
+		for k, _, ok := c.First(); ok; k, _, ok = c.Next() {
+			keys = append(keys, k)
+		}
+		if err := c.Err(); err != nil {
+			t.Fatal(err)
+		}
+		exp := []int64{-1 << 40, -1, 0, 5, 1 << 40}
+		if len(keys) != len(exp) {
+			t.Fatalf("unexpected keys: %v", keys)
+		}
+		for i := range exp {
+			if keys[i] != exp[i] {
+				t.Fatalf("unexpected keys: %v", keys)
+			}
+		}
+
+		if k, v, ok := c.Seek(1); !ok || k != 5 || v.Count != 5 {
+			t.Fatalf("unexpected result: %v, %v, %v", k, v, ok)
+		} else if k, _, ok := c.Prev(); !ok || k != 0 {
+			t.Fatalf("unexpected result: %v, %v", k, ok)
+		} else if k, _, ok := c.Last(); !ok || k != 1<<40 {
+			t.Fatalf("unexpected result: %v, %v", k, ok)
+		} else if _, _, ok := c.Next(); ok {
+			t.Fatal("expected end of cursor")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure that a typed cursor reports decode errors.
+func TestTypedCursor_Err(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			t.Fatal(err)
+		} else if err := b.Put([]byte("short"), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+
+		c := bolt.NewTypedBucket[uint32, string](b, bolt.IntCodec[uint32]{}, bolt.StringCodec{}).Cursor()
+		if _, _, ok := c.First(); ok {
+			t.Fatal("expected failure")
+		} else if c.Err() == nil {
+			t.Fatal("expected error")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure that integer codecs preserve order for every integer width.
+func TestIntCodec_Order(t *testing.T) {
+	var c8 bolt.IntCodec[int8]
+	prev, _ := c8.Encode(-128)
+	for i := -127; i <= 127; i++ {
+		b, err := c8.Encode(int8(i))
+		if err != nil {
+			t.Fatal(err)
+		} else if bytes.Compare(prev, b) >= 0 {
+			t.Fatalf("out of order at %d", i)
+		} else if v, err := c8.Decode(b); err != nil || v != int8(i) {
+			t.Fatalf("unexpected decode: %d, %v", v, err)
+		}
+		prev = b
+	}
+
+	var cu bolt.IntCodec[uint64]
+	lo, _ := cu.Encode(1)
+	hi, _ := cu.Encode(1 << 63)
+	if bytes.Compare(lo, hi) >= 0 {
+		t.Fatal("unsigned values out of order")
+	}
+}
+
+// Ensure that the time codec preserves order and round trips.
+func TestTimeCodec(t *testing.T) {
+	var c bolt.TimeCodec
+	times := []time.Time{
+		time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(1969, 12, 31, 23, 59, 59, 999999999, time.UTC),
+		time.Unix(0, 0),
+		time.Date(2016, 6, 1, 12, 0, 0, 5, time.FixedZone("X", 3600)),
+		time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	var prev []byte
+	for _, tm := range times {
+		b, err := c.Encode(tm)
+		if err != nil {
+			t.Fatal(err)
+		} else if prev != nil && bytes.Compare(prev, b) >= 0 {
+			t.Fatalf("out of order at %s", tm)
+		} else if v, err := c.Decode(b); err != nil || !v.Equal(tm) {
+			t.Fatalf("unexpected decode: %s, %v", v, err)
+		}
+		prev = b
+	}
+}